@@ -41,14 +41,24 @@ const (
 	U2FHID_ARMORY_INF = iota + u2fhid.VendorCommandFirst
 	// Trusted Applet configuration
 	U2FHID_ARMORY_CFG
-	// Obsolete
-	U2FHID_ARMORY_OTA_UNUSED
+	// Install an OS or applet update
+	U2FHID_ARMORY_OTA
 	// Set HAB fuse to built-in SRK hash
 	U2FHID_ARMORY_HAB
 	// Fetch latest debug/console logs
 	U2FHID_ARMORY_CONSOLE_LOGS
 	// Fetch stored crash logs from most recent applet crash
 	U2FHID_ARMORY_CRASH_LOGS
+	// Erase witness state, retaining installed firmware
+	U2FHID_ARMORY_FACTORY_RESET
+	// Boot the applet installed in the other slot (recovery mode only)
+	U2FHID_ARMORY_RECOVERY_SWITCH_SLOT
+	// Install an applet update (recovery mode only)
+	U2FHID_ARMORY_RECOVERY_INSTALL
+	// Get the progress of an update sent with U2FHID_ARMORY_OTA
+	U2FHID_ARMORY_OTA_STATUS
+	// Get the current Trusted Applet configuration
+	U2FHID_ARMORY_GET_CFG
 )
 
 var emptyResponse []byte
@@ -86,6 +96,22 @@ func (p *Configuration) Bytes() (buf []byte) {
 	return
 }
 
+// Print returns the Trusted Applet configuration in textual format.
+func (p *Configuration) Print() string {
+	var cfg bytes.Buffer
+
+	cfg.WriteString("-------------------------------------------------------- Configuration ----\n")
+	cfg.WriteString(fmt.Sprintf("DHCP .......................: %v\n", p.DHCP))
+	cfg.WriteString(fmt.Sprintf("IP .........................: %s\n", p.IP))
+	cfg.WriteString(fmt.Sprintf("Netmask ....................: %s\n", p.Netmask))
+	cfg.WriteString(fmt.Sprintf("Gateway ....................: %s\n", p.Gateway))
+	cfg.WriteString(fmt.Sprintf("Resolver ...................: %s\n", p.Resolver))
+	cfg.WriteString(fmt.Sprintf("NTP server .................: %s\n", p.NTPServer))
+	cfg.WriteString("-------------------------------------------------------- Configuration ----")
+
+	return cfg.String()
+}
+
 // Print returns the Trusted OS status in textual format.
 func (p *Status) Print() string {
 	var status bytes.Buffer
@@ -97,9 +123,13 @@ func (p *Status) Print() string {
 	status.WriteString(fmt.Sprintf("Revision ...................: %s\n", p.Revision))
 	status.WriteString(fmt.Sprintf("Version ....................: %s\n", p.Version))
 	status.WriteString(fmt.Sprintf("Runtime ....................: %s\n", p.Runtime))
+	status.WriteString(fmt.Sprintf("Applet version .............: %s\n", p.AppletVersion))
 	status.WriteString(fmt.Sprintf("Link .......................: %v\n", p.Link))
 	status.WriteString(fmt.Sprintf("MAC ........................: %v\n", p.MAC))
 	status.WriteString(fmt.Sprintf("IdentityCounter ............: %d\n", p.IdentityCounter))
+	if p.Recovery {
+		status.WriteString(fmt.Sprintf("Recovery mode ..............: %s\n", p.RecoveryReason))
+	}
 	if p.Witness != nil {
 		status.WriteString(fmt.Sprintf("Witness/Identity ...........: %v\n", p.Witness.Identity))
 		status.WriteString(fmt.Sprintf("Witness/IP .................: %v\n", p.Witness.IP))
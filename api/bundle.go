@@ -0,0 +1,184 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/transparency-dev/armored-witness-boot/config"
+	"github.com/transparency-dev/armored-witness-common/release/firmware"
+	"github.com/transparency-dev/armored-witness-common/release/firmware/ftlog"
+)
+
+const (
+	// ProofBundleMagic identifies serialized proof bundles.
+	ProofBundleMagic = "AWPROOF"
+	// ProofBundleVersion is the proof bundle format version, it follows
+	// ProofBundleMagic in serialized proof bundles.
+	ProofBundleVersion = 1
+
+	// MaxProofBundleSize is the maximum length of a serialized proof
+	// bundle.
+	MaxProofBundleSize = 64 * 1024
+)
+
+// NewProofBundle returns the proof bundle for a firmware bundle, the firmware
+// image is not included.
+func NewProofBundle(b *firmware.Bundle) *ProofBundle {
+	return &ProofBundle{
+		Checkpoint:     b.Checkpoint,
+		Manifest:       b.Manifest,
+		LogIndex:       b.Index,
+		InclusionProof: b.InclusionProof,
+	}
+}
+
+// ParseProofBundle parses a serialized proof bundle.
+//
+// For migration purposes, proof bundles in the legacy gob encoding of
+// firmware.Bundle are also accepted.
+func ParseProofBundle(buf []byte) (*ProofBundle, error) {
+	if len(buf) > MaxProofBundleSize {
+		return nil, fmt.Errorf("proof bundle exceeds %d bytes", MaxProofBundleSize)
+	}
+
+	if !bytes.HasPrefix(buf, []byte(ProofBundleMagic)) {
+		return parseLegacyProofBundle(buf)
+	}
+
+	buf = buf[len(ProofBundleMagic):]
+
+	if len(buf) == 0 || buf[0] != ProofBundleVersion {
+		return nil, errors.New("unsupported proof bundle version")
+	}
+
+	p := &ProofBundle{}
+	if err := proto.Unmarshal(buf[1:], p); err != nil {
+		return nil, fmt.Errorf("invalid proof bundle: %v", err)
+	}
+
+	if len(p.ProtoReflect().GetUnknown()) > 0 {
+		return nil, errors.New("invalid proof bundle: unknown fields")
+	}
+
+	return p, p.validate()
+}
+
+// parseLegacyProofBundle parses a gob encoded firmware.Bundle.
+func parseLegacyProofBundle(buf []byte) (*ProofBundle, error) {
+	b := &firmware.Bundle{}
+	if err := gob.NewDecoder(bytes.NewBuffer(buf)).Decode(b); err != nil {
+		return nil, fmt.Errorf("invalid proof bundle: %v", err)
+	}
+
+	p := NewProofBundle(b)
+
+	return p, p.validate()
+}
+
+func (p *ProofBundle) validate() error {
+	if len(p.Checkpoint) == 0 {
+		return errors.New("invalid proof bundle: missing checkpoint")
+	}
+
+	if len(p.Manifest) == 0 {
+		return errors.New("invalid proof bundle: missing manifest")
+	}
+
+	return nil
+}
+
+// Bytes serializes a proof bundle.
+func (p *ProofBundle) Bytes() (buf []byte, err error) {
+	if err = p.validate(); err != nil {
+		return
+	}
+
+	m, err := proto.Marshal(p)
+	if err != nil {
+		return
+	}
+
+	buf = append([]byte(ProofBundleMagic), ProofBundleVersion)
+	buf = append(buf, m...)
+
+	if len(buf) > MaxProofBundleSize {
+		return nil, fmt.Errorf("proof bundle exceeds %d bytes", MaxProofBundleSize)
+	}
+
+	return
+}
+
+// Bundle returns the firmware bundle for the given firmware image.
+func (p *ProofBundle) Bundle(elf []byte) firmware.Bundle {
+	return firmware.Bundle{
+		Checkpoint:     p.Checkpoint,
+		Index:          p.LogIndex,
+		InclusionProof: p.InclusionProof,
+		Manifest:       p.Manifest,
+		Firmware:       elf,
+	}
+}
+
+// Release returns the contents of the proof bundle manifest, its signatures
+// are not verified.
+func (p *ProofBundle) Release() (*ftlog.FirmwareRelease, error) {
+	return ParseManifest(p.Manifest)
+}
+
+// ManifestText returns the text of a signed manifest note, signatures are not
+// verified.
+func ManifestText(m []byte) ([]byte, error) {
+	// The note text is separated from its signatures by a blank line.
+	i := bytes.LastIndex(m, []byte("\n\n"))
+	if i < 0 {
+		return nil, errors.New("malformed manifest note")
+	}
+
+	return m[:i+1], nil
+}
+
+// ParseManifest returns the firmware release contained in a signed manifest
+// note, signatures are not verified therefore it must only be used on
+// manifests which have been, or will be, verified.
+func ParseManifest(m []byte) (*ftlog.FirmwareRelease, error) {
+	text, err := ManifestText(m)
+	if err != nil {
+		return nil, err
+	}
+
+	release := &ftlog.FirmwareRelease{}
+	if err := json.Unmarshal(text, release); err != nil {
+		return nil, fmt.Errorf("invalid manifest contents: %v", err)
+	}
+
+	return release, nil
+}
+
+// Config returns the proof bundle in armored-witness-boot format.
+func (p *ProofBundle) Config() config.ProofBundle {
+	return config.ProofBundle{
+		Checkpoint:     p.Checkpoint,
+		Manifest:       p.Manifest,
+		LogIndex:       p.LogIndex,
+		InclusionProof: p.InclusionProof,
+	}
+}
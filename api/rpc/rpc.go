@@ -15,8 +15,21 @@
 package rpc
 
 import (
+	"fmt"
+
 	"github.com/coreos/go-semver/semver"
 	"github.com/transparency-dev/armored-witness-boot/config"
+	"github.com/transparency-dev/armored-witness-common/release/firmware"
+	"github.com/transparency-dev/armored-witness-common/release/firmware/ftlog"
+)
+
+// The applet data region is the internal eMMC region, in blocks, reserved for
+// the Trusted Applet own data (e.g. its configuration and witness state). It
+// is the only region the applet can write with the WriteBlocks RPC, and it is
+// erased on factory reset.
+const (
+	AppletDataBlock     = 0x400000
+	AppletDataNumBlocks = 0x200000 // 1GB
 )
 
 // Handler represents an RPC request for event handler registration.
@@ -57,16 +70,237 @@ type WitnessStatus struct {
 	AttestedBastionID string
 }
 
+// FirmwareType represents the types of updatable firmware.
+type FirmwareType int
+
+const (
+	FirmwareApplet FirmwareType = iota
+	FirmwareOS
+)
+
+func (ft FirmwareType) String() string {
+	switch ft {
+	case FirmwareApplet:
+		return "applet"
+	case FirmwareOS:
+		return "OS"
+	}
+	return fmt.Sprintf("FirmwareType(%d)", int(ft))
+}
+
 // FirmwareUpdate represents a firmware update.
 type FirmwareUpdate struct {
 	// Sequence is a counter used to ensure correct ordering of chunks of firmware.
 	Sequence uint
 
+	// Offset is the position of Image within the complete firmware image,
+	// it must match the number of bytes sent with all previous chunks.
+	//
+	// A zero Offset on any chunk but the first is taken to be contiguous
+	// with the previous chunk, for compatibility with clients which predate
+	// this field.
+	Offset int64
+
+	// Digest, if set, is the SHA256 digest of Image and is used to detect
+	// corrupted chunks.
+	Digest []byte
+
 	// Image is the firmware image to be applied.
 	Image []byte
 
+	// DeltaBase, if set, indicates that the complete firmware image is a
+	// binary delta (see internal/delta) against the active firmware image,
+	// as stored, with this SHA256 digest.
+	DeltaBase []byte
+
+	// ProofBundle is the serialized proof bundle (see api.ProofBundle) for
+	// the new firmware image, when set it takes precedence over Proof.
+	ProofBundle []byte
+
 	//  Proof contains firmware transparency artefacts for the new firmware image.
+	//
+	// Deprecated: retained for migration, use ProofBundle.
 	Proof config.ProofBundle
+
+	// ConsistencyProof proves that the Proof checkpoint is consistent with
+	// the latest checkpoint verified by the OS (see LogState), from the
+	// smaller to the larger of the two, it is required when their sizes
+	// differ.
+	ConsistencyProof [][]byte
+}
+
+// FirmwareUpdateStatus represents the progress of a chunked firmware update.
+type FirmwareUpdateStatus struct {
+	// Sequence is the value expected in the Sequence field of the next chunk.
+	Sequence uint
+
+	// Offset is the number of firmware image bytes received so far, and the
+	// value expected in the Offset field of the next chunk.
+	Offset int64
+}
+
+// CombinedUpdate represents an update of both the OS and applet, applied as
+// a single transaction. Either field can be nil when only a chunk of the
+// other firmware image is being sent.
+type CombinedUpdate struct {
+	// OS is a chunk of the OS firmware update.
+	OS *FirmwareUpdate
+	// Applet is a chunk of the applet firmware update.
+	Applet *FirmwareUpdate
+}
+
+// CombinedUpdateStatus represents the progress of a combined update.
+type CombinedUpdateStatus struct {
+	// OS is the progress of the chunked OS firmware update.
+	OS FirmwareUpdateStatus
+	// OSStaged indicates that the OS update has been verified and staged.
+	OSStaged bool
+	// Applet is the progress of the chunked applet firmware update.
+	Applet FirmwareUpdateStatus
+	// AppletStaged indicates that the applet update has been verified and
+	// staged.
+	AppletStaged bool
+}
+
+// StagedFirmware represents a verified firmware update, written to the
+// inactive slot, which is pending activation.
+type StagedFirmware struct {
+	// Staged is false when no update is pending activation, in which case
+	// all other fields are unset.
+	Staged bool
+	// Version is the semantic version of the staged firmware.
+	Version semver.Version
+	// Size is the length in bytes of the staged firmware image, as stored
+	// (i.e. compressed, if the image was sent compressed).
+	Size int64
+	// Proof contains firmware transparency artefacts for the staged
+	// firmware image.
+	Proof config.ProofBundle
+	// Signers lists the keys, in <name>+<hash> format, which signed the
+	// staged firmware manifest.
+	Signers []string
+}
+
+// VerifyBundle represents an RPC request for firmware bundle verification.
+type VerifyBundle struct {
+	// Type is the type of firmware contained in the bundle.
+	Type FirmwareType
+	// Bundle is the firmware bundle to be verified.
+	Bundle firmware.Bundle
+	// ConsistencyProof proves that the bundle checkpoint is consistent
+	// with the latest checkpoint verified by the OS.
+	ConsistencyProof [][]byte
+}
+
+// LogState represents the latest firmware log checkpoint verified by the OS.
+type LogState struct {
+	// Origin is the firmware log origin string.
+	Origin string
+	// Size is the tree size of the latest checkpoint, zero if no
+	// checkpoint has been verified yet.
+	Size uint64
+	// Hash is the tree hash of the latest checkpoint.
+	Hash []byte
+}
+
+// BundleVerification represents the outcome of firmware bundle verification.
+type BundleVerification struct {
+	// Manifest is the parsed manifest of a bundle which passed verification.
+	Manifest *ftlog.FirmwareRelease
+	// Rejection is the reason for a bundle failing verification.
+	Rejection *BundleRejection
+}
+
+// BundleRejectionReason identifies the firmware bundle verification check
+// which failed.
+type BundleRejectionReason int
+
+const (
+	// RejectedFirmwareType indicates an unknown firmware type.
+	RejectedFirmwareType BundleRejectionReason = iota + 1
+	// RejectedCheckpoint indicates a checkpoint which is malformed or not
+	// signed by the expected log.
+	RejectedCheckpoint
+	// RejectedManifest indicates a manifest which is malformed or lacks
+	// required signatures.
+	RejectedManifest
+	// RejectedComponent indicates a manifest for a different type of
+	// firmware.
+	RejectedComponent
+	// RejectedFirmwareDigest indicates a firmware image which does not
+	// match the manifest.
+	RejectedFirmwareDigest
+	// RejectedInclusionProof indicates a manifest which is not proven to be
+	// committed to by the checkpoint.
+	RejectedInclusionProof
+	// RejectedRollback indicates a firmware version older than the rollback
+	// protection floor.
+	RejectedRollback
+	// RejectedCompression indicates a compressed firmware image which is
+	// invalid, or not supported for the firmware type.
+	RejectedCompression
+	// RejectedCosignatures indicates a checkpoint lacking the witness
+	// cosignatures required by the OS witness policy.
+	RejectedCosignatures
+	// RejectedConsistency indicates a checkpoint which is inconsistent with
+	// the latest one verified by the OS, revealing a log fork or rollback.
+	RejectedConsistency
+	// RejectedRevoked indicates a manifest signed with a revoked key.
+	RejectedRevoked
+	// RejectedRuntime indicates an applet built with a TamaGo runtime not
+	// supported by the OS.
+	RejectedRuntime
+)
+
+func (r BundleRejectionReason) String() string {
+	switch r {
+	case RejectedFirmwareType:
+		return "invalid firmware type"
+	case RejectedCheckpoint:
+		return "invalid checkpoint"
+	case RejectedManifest:
+		return "invalid manifest"
+	case RejectedComponent:
+		return "wrong manifest component"
+	case RejectedFirmwareDigest:
+		return "firmware digest mismatch"
+	case RejectedInclusionProof:
+		return "invalid inclusion proof"
+	case RejectedRollback:
+		return "firmware rollback"
+	case RejectedCompression:
+		return "invalid compression"
+	case RejectedCosignatures:
+		return "insufficient witness cosignatures"
+	case RejectedConsistency:
+		return "inconsistent checkpoint"
+	case RejectedRevoked:
+		return "revoked manifest key"
+	case RejectedRuntime:
+		return "unsupported runtime"
+	}
+	return fmt.Sprintf("BundleRejectionReason(%d)", int(r))
+}
+
+// BundleRejection represents a firmware bundle verification failure.
+type BundleRejection struct {
+	// Reason identifies the verification check which failed.
+	Reason BundleRejectionReason
+	// Detail is a human readable description of the failure.
+	Detail string
+}
+
+func (r *BundleRejection) Error() string {
+	return fmt.Sprintf("%v: %s", r.Reason, r.Detail)
+}
+
+// Revocations represents the manifest signer revocation list in effect.
+type Revocations struct {
+	// Version is the revocation list version, zero if no list has been
+	// installed.
+	Version uint64
+	// Revoked contains the revoked keys, in <name>+<hash> format.
+	Revoked []string
 }
 
 // InstalledVersions represents the installed/running versions
@@ -0,0 +1,183 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/coreos/go-semver/semver"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/transparency-dev/armored-witness-common/release/firmware/ftlog"
+)
+
+const (
+	// UpdatePackageMagic identifies serialized update packages.
+	UpdatePackageMagic = "AWUPDATE"
+	// UpdatePackageVersion is the update package format version, it
+	// follows UpdatePackageMagic in serialized update packages.
+	UpdatePackageVersion = 1
+	// UpdatePackageHeaderSize is the length of the header preceding the
+	// UpdatePackage message in serialized update packages.
+	UpdatePackageHeaderSize = len(UpdatePackageMagic) + 1 + 4
+
+	// MaxFirmwareSize is the maximum length of a firmware image, both as
+	// stored and decompressed. The firmware slots are far larger, the limit
+	// comes from the Trusted OS RAM (224MB, see trusted_os/mem.go) which
+	// must hold the images being received along with their copies made
+	// while staging them (see trusted_os/ota.go).
+	MaxFirmwareSize = 31457280
+
+	// MaxUpdatePackageSize is the maximum length of a serialized update
+	// package: the largest firmware image and proof bundle, with allowance
+	// for the header, the remaining fields and their encoding.
+	MaxUpdatePackageSize = MaxFirmwareSize + MaxProofBundleSize + 1024
+)
+
+// UpdatePackageSize returns the total length of a serialized update package
+// from its header, allowing packages received in chunks to be recognized as
+// complete.
+func UpdatePackageSize(header []byte) (int, error) {
+	if len(header) < UpdatePackageHeaderSize {
+		return 0, errors.New("invalid update package: short header")
+	}
+
+	if !bytes.HasPrefix(header, []byte(UpdatePackageMagic)) {
+		return 0, errors.New("invalid update package: bad magic")
+	}
+
+	if header[len(UpdatePackageMagic)] != UpdatePackageVersion {
+		return 0, errors.New("unsupported update package version")
+	}
+
+	n := int64(binary.BigEndian.Uint32(header[len(UpdatePackageMagic)+1:]))
+	n += int64(UpdatePackageHeaderSize)
+
+	if n > MaxUpdatePackageSize {
+		return 0, fmt.Errorf("update package exceeds %d bytes", MaxUpdatePackageSize)
+	}
+
+	return int(n), nil
+}
+
+// ParseUpdatePackage parses a serialized update package.
+//
+// Besides the format, the consistency of the package metadata with its
+// contents and with the (unverified) proof bundle manifest is checked.
+// Verification of the proof bundle is left to the installer.
+func ParseUpdatePackage(buf []byte) (*UpdatePackage, error) {
+	n, err := UpdatePackageSize(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(buf) != n {
+		return nil, fmt.Errorf("invalid update package: length %d, expected %d", len(buf), n)
+	}
+
+	p := &UpdatePackage{}
+	if err := proto.Unmarshal(buf[UpdatePackageHeaderSize:], p); err != nil {
+		return nil, fmt.Errorf("invalid update package: %v", err)
+	}
+
+	if len(p.ProtoReflect().GetUnknown()) > 0 || (p.Proof != nil && len(p.Proof.ProtoReflect().GetUnknown()) > 0) {
+		return nil, errors.New("invalid update package: unknown fields")
+	}
+
+	return p, p.validate()
+}
+
+func (p *UpdatePackage) validate() error {
+	var component string
+
+	switch p.Type {
+	case FirmwareType_APPLET:
+		component = ftlog.ComponentApplet
+	case FirmwareType_OS:
+		component = ftlog.ComponentOS
+	default:
+		return fmt.Errorf("invalid update package: unknown firmware type %v", p.Type)
+	}
+
+	if len(p.Firmware) == 0 {
+		return errors.New("invalid update package: missing firmware")
+	}
+
+	if len(p.Firmware) > MaxFirmwareSize {
+		return fmt.Errorf("invalid update package: firmware exceeds %d bytes", MaxFirmwareSize)
+	}
+
+	if h := sha256.Sum256(p.Firmware); !bytes.Equal(h[:], p.FirmwareSHA256) {
+		return fmt.Errorf("invalid update package: firmware digest mismatch (%x != %x)", h, p.FirmwareSHA256)
+	}
+
+	if len(p.DeltaBase) != 0 && len(p.DeltaBase) != sha256.Size {
+		return errors.New("invalid update package: invalid delta base")
+	}
+
+	if p.Proof == nil {
+		return errors.New("invalid update package: missing proof bundle")
+	}
+
+	if err := p.Proof.validate(); err != nil {
+		return err
+	}
+
+	version, err := semver.NewVersion(p.Version)
+	if err != nil {
+		return fmt.Errorf("invalid update package: invalid version: %v", err)
+	}
+
+	release, err := p.Proof.Release()
+	if err != nil {
+		return fmt.Errorf("invalid update package: %v", err)
+	}
+
+	if release.Component != component {
+		return fmt.Errorf("invalid update package: %v package contains %s manifest", p.Type, release.Component)
+	}
+
+	if !version.Equal(release.Git.TagName) {
+		return fmt.Errorf("invalid update package: version %v does not match manifest version %v", version, release.Git.TagName)
+	}
+
+	return nil
+}
+
+// Bytes serializes an update package.
+func (p *UpdatePackage) Bytes() (buf []byte, err error) {
+	if err = p.validate(); err != nil {
+		return
+	}
+
+	m, err := proto.Marshal(p)
+	if err != nil {
+		return
+	}
+
+	if len(m) > MaxUpdatePackageSize-UpdatePackageHeaderSize {
+		return nil, fmt.Errorf("update package exceeds %d bytes", MaxUpdatePackageSize)
+	}
+
+	buf = append([]byte(UpdatePackageMagic), UpdatePackageVersion)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(m)))
+	buf = append(buf, m...)
+
+	return
+}
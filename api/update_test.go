@@ -0,0 +1,245 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"golang.org/x/mod/sumdb/note"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/transparency-dev/armored-witness-common/release/firmware/ftlog"
+)
+
+func signedManifest(t *testing.T, component string, version string) []byte {
+	t.Helper()
+
+	skey, _, err := note.GenerateKey(rand.Reader, "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := note.NewSigner(skey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := fmt.Sprintf(`{"component":%q,"git":{"tag_name":%q}}`+"\n", component, version)
+
+	m, err := note.Sign(&note.Note{Text: text}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func testPackage(t *testing.T) *UpdatePackage {
+	t.Helper()
+
+	fw := []byte("firmware image")
+	h := sha256.Sum256(fw)
+
+	return &UpdatePackage{
+		Type:           FirmwareType_APPLET,
+		Version:        "1.2.3",
+		Firmware:       fw,
+		FirmwareSHA256: h[:],
+		Proof: &ProofBundle{
+			Checkpoint: []byte("checkpoint"),
+			Manifest:   signedManifest(t, ftlog.ComponentApplet, "1.2.3"),
+		},
+	}
+}
+
+// serialize returns a serialized update package, without validating it.
+func serialize(t *testing.T, p *UpdatePackage) []byte {
+	t.Helper()
+
+	m, err := proto.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := append([]byte(UpdatePackageMagic), UpdatePackageVersion)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(m)))
+
+	return append(buf, m...)
+}
+
+func TestUpdatePackageRoundTrip(t *testing.T) {
+	p := testPackage(t)
+
+	buf, err := p.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes(): %v", err)
+	}
+
+	if n, err := UpdatePackageSize(buf[:UpdatePackageHeaderSize]); err != nil || n != len(buf) {
+		t.Errorf("UpdatePackageSize() = %d, %v, want %d", n, err, len(buf))
+	}
+
+	got, err := ParseUpdatePackage(buf)
+	if err != nil {
+		t.Fatalf("ParseUpdatePackage(): %v", err)
+	}
+
+	if !proto.Equal(got, p) {
+		t.Errorf("ParseUpdatePackage() = %v, want %v", got, p)
+	}
+}
+
+func TestParseUpdatePackageErrors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		// buf returns the serialized update package to parse.
+		buf func(t *testing.T) []byte
+	}{
+		{
+			name: "bad magic",
+			buf: func(t *testing.T) []byte {
+				buf := serialize(t, testPackage(t))
+				buf[0] ^= 0xff
+				return buf
+			},
+		},
+		{
+			name: "bad version",
+			buf: func(t *testing.T) []byte {
+				buf := serialize(t, testPackage(t))
+				buf[len(UpdatePackageMagic)] = UpdatePackageVersion + 1
+				return buf
+			},
+		},
+		{
+			name: "short header",
+			buf: func(t *testing.T) []byte {
+				return serialize(t, testPackage(t))[:UpdatePackageHeaderSize-1]
+			},
+		},
+		{
+			name: "truncated",
+			buf: func(t *testing.T) []byte {
+				buf := serialize(t, testPackage(t))
+				return buf[:len(buf)-1]
+			},
+		},
+		{
+			name: "trailing data",
+			buf: func(t *testing.T) []byte {
+				return append(serialize(t, testPackage(t)), 0)
+			},
+		},
+		{
+			name: "oversized",
+			buf: func(t *testing.T) []byte {
+				buf := serialize(t, testPackage(t))
+				binary.BigEndian.PutUint32(buf[len(UpdatePackageMagic)+1:], MaxUpdatePackageSize)
+				return buf
+			},
+		},
+		{
+			name: "unknown fields",
+			buf: func(t *testing.T) []byte {
+				p := testPackage(t)
+				p.ProtoReflect().SetUnknown(protowire.AppendVarint(protowire.AppendTag(nil, 100, protowire.VarintType), 1))
+				return serialize(t, p)
+			},
+		},
+		{
+			name: "unknown proof bundle fields",
+			buf: func(t *testing.T) []byte {
+				p := testPackage(t)
+				p.Proof.ProtoReflect().SetUnknown(protowire.AppendVarint(protowire.AppendTag(nil, 100, protowire.VarintType), 1))
+				return serialize(t, p)
+			},
+		},
+		{
+			name: "unknown firmware type",
+			buf: func(t *testing.T) []byte {
+				p := testPackage(t)
+				p.Type = FirmwareType(2)
+				return serialize(t, p)
+			},
+		},
+		{
+			name: "type and manifest mismatch",
+			buf: func(t *testing.T) []byte {
+				p := testPackage(t)
+				p.Type = FirmwareType_OS
+				return serialize(t, p)
+			},
+		},
+		{
+			name: "version and manifest mismatch",
+			buf: func(t *testing.T) []byte {
+				p := testPackage(t)
+				p.Version = "1.2.4"
+				return serialize(t, p)
+			},
+		},
+		{
+			name: "digest mismatch",
+			buf: func(t *testing.T) []byte {
+				p := testPackage(t)
+				p.Firmware = []byte("other firmware image")
+				return serialize(t, p)
+			},
+		},
+		{
+			name: "missing firmware",
+			buf: func(t *testing.T) []byte {
+				p := testPackage(t)
+				p.Firmware = nil
+				return serialize(t, p)
+			},
+		},
+		{
+			name: "missing proof bundle",
+			buf: func(t *testing.T) []byte {
+				p := testPackage(t)
+				p.Proof = nil
+				return serialize(t, p)
+			},
+		},
+		{
+			name: "invalid delta base",
+			buf: func(t *testing.T) []byte {
+				p := testPackage(t)
+				p.DeltaBase = []byte{1, 2, 3}
+				return serialize(t, p)
+			},
+		},
+		{
+			name: "unsigned manifest",
+			buf: func(t *testing.T) []byte {
+				p := testPackage(t)
+				p.Proof.Manifest = []byte(`{"component":"TRUSTED_APPLET","git":{"tag_name":"1.2.3"}}` + "\n")
+				return serialize(t, p)
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ParseUpdatePackage(test.buf(t)); err == nil {
+				t.Errorf("ParseUpdatePackage() succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2026 The Armored Witness authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"k8s.io/klog"
+)
+
+// summary represents the release summary written in batch mode, describing
+// the bundles built and the checkpoint they share.
+type summary struct {
+	LogOrigin  string          `json:"log_origin"`
+	TreeSize   uint64          `json:"tree_size"`
+	RootHash   string          `json:"root_hash"`
+	Checkpoint string          `json:"checkpoint"`
+	Bundles    []bundleSummary `json:"bundles"`
+}
+
+// bundleSummary describes a bundle built in batch mode.
+type bundleSummary struct {
+	Component      string `json:"component"`
+	Version        string `json:"version"`
+	FirmwareFile   string `json:"firmware_file"`
+	FirmwareSHA256 string `json:"firmware_sha256"`
+	ManifestFile   string `json:"manifest_file"`
+	LogIndex       uint64 `json:"log_index"`
+	BundleFile     string `json:"bundle_file"`
+	BundleSHA256   string `json:"bundle_sha256"`
+	PackageFile    string `json:"package_file,omitempty"`
+	PackageSHA256  string `json:"package_sha256,omitempty"`
+}
+
+// buildBatch builds the bundles for all releases listed in a batch file,
+// against a single checkpoint, and writes the release summary.
+//
+// The batch file is a JSON list of releases, for example:
+//
+//	[
+//	  {
+//	    "firmware_type": "os",
+//	    "firmware_file": "bin/trusted_os.elf",
+//	    "manifest_file": "bin/trusted_os_manifest",
+//	    "manifest_pubkey_files": ["os1.pub", "os2.pub"],
+//	    "output_file": "bin/trusted_os.proofbundle",
+//	    "package_file": "bin/trusted_os.update"
+//	  },
+//	  ...
+//	]
+func buildBatch(ctx context.Context, batchFile string, summaryFile string) {
+	releases, err := parseBatch(batchFile)
+	if err != nil {
+		klog.Exitf("Invalid batch file %q: %v", batchFile, err)
+	}
+
+	cpRaw, cp := buildBundles(ctx, releases)
+
+	s := summary{
+		LogOrigin:  cp.Origin,
+		TreeSize:   cp.Size,
+		RootHash:   fmt.Sprintf("%x", cp.Hash),
+		Checkpoint: string(cpRaw),
+	}
+
+	for _, r := range releases {
+		fh := sha256.Sum256(r.firmware)
+		bh := sha256.Sum256(r.bundle)
+
+		b := bundleSummary{
+			Component:      r.release.Component,
+			Version:        r.release.Git.TagName.String(),
+			FirmwareFile:   r.FirmwareFile,
+			FirmwareSHA256: fmt.Sprintf("%x", fh),
+			ManifestFile:   r.ManifestFile,
+			LogIndex:       r.index,
+			BundleFile:     r.OutputFile,
+			BundleSHA256:   fmt.Sprintf("%x", bh),
+		}
+
+		if len(r.pkg) > 0 {
+			ph := sha256.Sum256(r.pkg)
+			b.PackageFile = r.PackageFile
+			b.PackageSHA256 = fmt.Sprintf("%x", ph)
+		}
+
+		s.Bundles = append(s.Bundles, b)
+	}
+
+	jsn, err := json.MarshalIndent(&s, "", "  ")
+	if err != nil {
+		klog.Exitf("Failed to encode summary: %v", err)
+	}
+
+	if len(summaryFile) == 0 {
+		fmt.Printf("%s\n", jsn)
+		return
+	}
+
+	if err := os.WriteFile(summaryFile, append(jsn, '\n'), 0o644); err != nil {
+		klog.Exitf("WriteFile: %v", err)
+	}
+
+	klog.Infof("Wrote release summary to %q", summaryFile)
+}
+
+// parseBatch parses a batch file, rejecting unknown fields and duplicate
+// output files.
+func parseBatch(p string) ([]*release, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+
+	releases := []*release{}
+	if err := d.Decode(&releases); err != nil {
+		return nil, err
+	}
+
+	if len(releases) == 0 {
+		return nil, fmt.Errorf("no releases listed")
+	}
+
+	outputs := make(map[string]bool)
+
+	for i, r := range releases {
+		if len(r.FirmwareFile) == 0 || len(r.ManifestFile) == 0 || len(r.OutputFile) == 0 || len(r.ManifestPubKeyFiles) == 0 {
+			return nil, fmt.Errorf("release %d: firmware_file, manifest_file, manifest_pubkey_files and output_file are required", i)
+		}
+
+		for _, f := range []string{r.OutputFile, r.PackageFile} {
+			if len(f) == 0 {
+				continue
+			}
+			if outputs[f] {
+				return nil, fmt.Errorf("release %d: duplicate output file %q", i, f)
+			}
+			outputs[f] = true
+		}
+	}
+
+	return releases, nil
+}
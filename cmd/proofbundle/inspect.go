@@ -0,0 +1,115 @@
+// Copyright 2026 The Armored Witness authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/transparency-dev/armored-witness-common/release/firmware/ftlog"
+	"github.com/transparency-dev/armored-witness-os/api"
+	fmtlog "github.com/transparency-dev/formats/log"
+	"k8s.io/klog"
+)
+
+// inspect prints the contents of proof bundle files, signatures are not
+// verified (see verify).
+func inspect(args []string) {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s inspect <bundle file>...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	for _, f := range fs.Args() {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			klog.Exitf("Failed to read bundle %q: %v", f, err)
+		}
+
+		pb, err := api.ParseProofBundle(b)
+		if err != nil {
+			klog.Exitf("Failed to parse bundle %q: %v", f, err)
+		}
+
+		format := fmt.Sprintf("v%d", api.ProofBundleVersion)
+		if !bytes.HasPrefix(b, []byte(api.ProofBundleMagic)) {
+			format = "legacy (gob)"
+		}
+
+		field("Bundle", f)
+		field("Format", format)
+		field("Index", pb.LogIndex)
+		field("Inclusion proof", fmt.Sprintf("%d hashes", len(pb.InclusionProof)))
+
+		text, sigs := splitNote(pb.Checkpoint)
+		cp := &fmtlog.Checkpoint{}
+		if _, err := cp.Unmarshal(text); err != nil {
+			field("Checkpoint", fmt.Sprintf("invalid (%v)", err))
+		} else {
+			field("Checkpoint", fmt.Sprintf("%s @ %d (%x)", cp.Origin, cp.Size, cp.Hash))
+		}
+		printSignatures("Checkpoint", sigs)
+
+		text, sigs = splitNote(pb.Manifest)
+		fr := ftlog.FirmwareRelease{}
+		if err := json.Unmarshal(text, &fr); err != nil {
+			field("Manifest", fmt.Sprintf("invalid (%v)", err))
+		} else {
+			field("Component", fr.Component)
+			field("Version", fr.Git.TagName.String())
+			field("Commit", fr.Git.CommitFingerprint)
+			field("TamaGo", fr.Build.TamagoVersion.String())
+			field("Firmware SHA256", fmt.Sprintf("%x", fr.Output.FirmwareDigestSha256))
+		}
+		printSignatures("Manifest", sigs)
+
+		field("Manifest text", fmt.Sprintf("[below]\n\n%s", text))
+	}
+}
+
+// splitNote returns the text and signature lines of a signed note.
+func splitNote(n []byte) ([]byte, []string) {
+	i := bytes.LastIndex(n, []byte("\n\n"))
+	if i < 0 {
+		return n, nil
+	}
+
+	return n[:i+1], strings.Split(strings.TrimSpace(string(n[i+2:])), "\n")
+}
+
+func printSignatures(what string, sigs []string) {
+	for _, sig := range sigs {
+		// signature lines are "— <name> <base64 key hash and signature>"
+		if f := strings.Fields(sig); len(f) == 3 {
+			field(what+" signer", f[1])
+		}
+	}
+}
+
+// field prints a labelled value.
+func field(label string, v any) {
+	fmt.Printf("%s %s: %v\n", label, strings.Repeat(".", 20-len(label)), v)
+}
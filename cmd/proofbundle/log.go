@@ -0,0 +1,77 @@
+// Copyright 2026 The Armored Witness authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+
+	fmtlog "github.com/transparency-dev/formats/log"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/serverless-log/client"
+	"golang.org/x/mod/sumdb/note"
+	"k8s.io/klog"
+)
+
+// logClient fetches checkpoints and proofs from a firmware transparency log.
+type logClient interface {
+	// Checkpoint returns the latest log checkpoint, verified against the
+	// log origin and key, both raw and parsed.
+	Checkpoint(ctx context.Context) ([]byte, *fmtlog.Checkpoint, error)
+	// LookupIndex returns the index of the leaf with the given hash, within
+	// the tree of the given size.
+	LookupIndex(ctx context.Context, leafHash []byte, size uint64) (uint64, error)
+	// InclusionProof returns the proof for the leaf at the given index
+	// being committed to by the given checkpoint.
+	InclusionProof(ctx context.Context, index uint64, cp fmtlog.Checkpoint) ([][]byte, error)
+}
+
+// newLogClientOrDie creates a logClient for the log of the given type at the
+// given root location.
+func newLogClientOrDie(logType string, logURL string, origin string, v note.Verifier) logClient {
+	f := newFetcherOrDie(logURL)
+
+	switch logType {
+	case "serverless":
+		return &serverlessLog{f: f, origin: origin, v: v}
+	case "tiles":
+		return &tiledLog{f: f, origin: origin, v: v}
+	}
+
+	klog.Exitf("Unknown log type %q", logType)
+	return nil
+}
+
+// serverlessLog is a logClient for logs using the serverless-log layout.
+type serverlessLog struct {
+	f      client.Fetcher
+	origin string
+	v      note.Verifier
+}
+
+func (l *serverlessLog) Checkpoint(ctx context.Context) ([]byte, *fmtlog.Checkpoint, error) {
+	cp, raw, _, err := client.FetchCheckpoint(ctx, l.f, l.v, l.origin)
+	return raw, cp, err
+}
+
+func (l *serverlessLog) LookupIndex(ctx context.Context, leafHash []byte, _ uint64) (uint64, error) {
+	return client.LookupIndex(ctx, l.f, leafHash)
+}
+
+func (l *serverlessLog) InclusionProof(ctx context.Context, index uint64, cp fmtlog.Checkpoint) ([][]byte, error) {
+	pb, err := client.NewProofBuilder(ctx, cp, rfc6962.DefaultHasher.HashChildren, l.f)
+	if err != nil {
+		return nil, err
+	}
+	return pb.InclusionProof(ctx, index)
+}
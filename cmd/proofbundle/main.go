@@ -12,167 +12,308 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 //
-// The proofbundle tool builds serialised proof bundles for use when
-// embedding the appled into the OS build, only useful for development work.
+// The proofbundle tool builds serialised proof bundles for OS and applet
+// firmware images, for example for embedding the applet into the OS build.
+//
+// Existing bundles can be examined with the inspect and verify subcommands:
+//
+//	proofbundle inspect <bundle file>...
+//	proofbundle verify -bundle_file=... -firmware_file=... [key flags]
+//
+// Bundles for all the firmware images of a release can be built, against the
+// same checkpoint, with the -batch_file flag (see buildBatch).
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/gob"
+	"crypto/sha256"
 	"encoding/json"
 	"flag"
-	"fmt"
-	"io"
-	"net/http"
-	"net/url"
 	"os"
+	"strings"
 
 	"github.com/transparency-dev/armored-witness-common/release/firmware"
 	"github.com/transparency-dev/armored-witness-common/release/firmware/ftlog"
+	"github.com/transparency-dev/armored-witness-os/api"
+	"github.com/transparency-dev/armored-witness-os/internal/delta"
+	"github.com/transparency-dev/armored-witness-os/internal/quorum"
+	fmtlog "github.com/transparency-dev/formats/log"
 	"github.com/transparency-dev/merkle/rfc6962"
-	"github.com/transparency-dev/serverless-log/client"
 	"golang.org/x/mod/sumdb/note"
 	"k8s.io/klog"
 )
 
 var (
-	outputFile         = flag.String("output_file", "", "File to write the bundle to.")
-	logBaseURL         = flag.String("log_url", "", "Base URL for the firmware transparency log to use.")
-	logOrigin          = flag.String("log_origin", "", "FT log origin string.")
-	logPubKeyFile      = flag.String("log_pubkey_file", "", "File containing the FT log's public key in Note verifier format.")
-	appletFile         = flag.String("applet_file", "", "Applet firmware image to build bundle for.")
-	manifestFile       = flag.String("manifest_file", "", "Manifest to build a bundle for.")
-	manifestPubKeyFile = flag.String("manifest_pubkey_file", "", "File containing a Note verifier string to verify manifest signatures.")
+	outputFile        = flag.String("output_file", "", "File to write the bundle to.")
+	logBaseURL        = flag.String("log_url", "", "Base URL for the firmware transparency log to use.")
+	logType           = flag.String("log_type", "serverless", "Layout of the firmware transparency log (serverless or tiles).")
+	logOrigin         = flag.String("log_origin", "", "FT log origin string.")
+	logPubKeyFile     = flag.String("log_pubkey_file", "", "File containing the FT log's public key in Note verifier format.")
+	firmwareType      = flag.String("firmware_type", "applet", "Type of firmware to build bundle for (applet or os).")
+	firmwareFile      = flag.String("firmware_file", "", "Firmware image to build bundle for.")
+	appletFile        = flag.String("applet_file", "", "Deprecated: use -firmware_file.")
+	manifestFile      = flag.String("manifest_file", "", "Manifest to build a bundle for.")
+	checkpointURL     = flag.String("checkpoint_url", "", "URL of a witness cosigned checkpoint (e.g. from a witness or distributor) to build the bundle against, the latest log checkpoint is used when unset.")
+	witnessKeysFile   = flag.String("witness_pubkeys_file", "", "File containing witness public keys, one per line in note or cosignature/v1 format, whose cosignatures are required on the checkpoint.")
+	witnessThreshold  = flag.Int("witness_threshold", 0, "Number of witness cosignatures required on the checkpoint, all witness keys are required when unset.")
+	witnessTimeout    = flag.Duration("witness_timeout", 0, "How long to wait for the checkpoint at -checkpoint_url to be cosigned by enough witnesses.")
+	manifestThreshold = flag.Int("manifest_threshold", 0, "Number of manifest public keys which must have signed the manifest, all keys are required when unset.")
+	batchFile         = flag.String("batch_file", "", "JSON file listing the releases to build bundles for against a single checkpoint, replaces the firmware and manifest flags.")
+	summaryFile       = flag.String("summary_file", "", "File to write the release summary to, in batch mode.")
+	packageFile       = flag.String("package_file", "", "File to write an update package, containing the firmware image and its proof bundle, to.")
+	deltaBaseFile     = flag.String("delta_base_file", "", "Firmware image, as installed on devices, to build the update package firmware as a binary delta against.")
+
+	manifestPubKeyFiles stringsFlag
 )
 
+func init() {
+	flag.Var(&manifestPubKeyFiles, "manifest_pubkey_file", "File containing a Note verifier string to verify manifest signatures, can be repeated.")
+}
+
+// stringsFlag is a repeatable string flag.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "inspect":
+			inspect(os.Args[2:])
+			return
+		case "verify":
+			verify(os.Args[2:])
+			return
+		}
+	}
+
 	flag.Parse()
+	build()
+}
+
+// build builds a proof bundle, for the firmware image and manifest given by
+// the command line flags, or for each of those listed in a batch file, from
+// the firmware transparency log.
+func build() {
 	ctx := context.Background()
 
-	mv := verifierOrDie(*manifestPubKeyFile, "manifest")
-	manifest, _ := loadManifestOrDie(*manifestFile, mv)
-	fwBin, err := os.ReadFile(*appletFile)
-	if err != nil {
-		klog.Exitf("Failed to read applet %q: %v", *appletFile, err)
+	if len(*batchFile) > 0 {
+		buildBatch(ctx, *batchFile, *summaryFile)
+		return
+	}
+
+	if len(*firmwareFile) == 0 {
+		*firmwareFile = *appletFile
+	}
+
+	buildBundles(ctx, []*release{{
+		FirmwareType:        *firmwareType,
+		FirmwareFile:        *firmwareFile,
+		ManifestFile:        *manifestFile,
+		ManifestPubKeyFiles: manifestPubKeyFiles,
+		ManifestThreshold:   *manifestThreshold,
+		OutputFile:          *outputFile,
+		PackageFile:         *packageFile,
+		DeltaBaseFile:       *deltaBaseFile,
+	}})
+}
+
+// release represents a firmware image and manifest to build a proof bundle
+// for.
+type release struct {
+	FirmwareType        string   `json:"firmware_type"`
+	FirmwareFile        string   `json:"firmware_file"`
+	ManifestFile        string   `json:"manifest_file"`
+	ManifestPubKeyFiles []string `json:"manifest_pubkey_files"`
+	ManifestThreshold   int      `json:"manifest_threshold,omitempty"`
+	OutputFile          string   `json:"output_file"`
+	PackageFile         string   `json:"package_file,omitempty"`
+	DeltaBaseFile       string   `json:"delta_base_file,omitempty"`
+
+	manifest []byte
+	firmware []byte
+	release  ftlog.FirmwareRelease
+	verifier []note.Verifier
+	index    uint64
+	bundle   []byte
+	pkg      []byte
+}
+
+// load reads and verifies the release manifest, and reads the firmware image.
+func (r *release) load() {
+	component := componentOrDie(r.FirmwareType)
+	mvs, threshold := manifestVerifiersOrDie(r.ManifestPubKeyFiles, r.ManifestThreshold)
+
+	var err error
+
+	if r.manifest, err = os.ReadFile(r.ManifestFile); err != nil {
+		klog.Exitf("Failed to read manifest %q: %v", r.ManifestFile, err)
+	}
+	r.release, r.verifier = verifyManifestOrDie(r.manifest, mvs, threshold, component)
+
+	if r.firmware, err = os.ReadFile(r.FirmwareFile); err != nil {
+		klog.Exitf("Failed to read firmware %q: %v", r.FirmwareFile, err)
+	}
+}
+
+// buildBundles builds, verifies and writes the proof bundles for the given
+// releases, all against the same checkpoint which is returned.
+func buildBundles(ctx context.Context, releases []*release) ([]byte, *fmtlog.Checkpoint) {
+	for _, r := range releases {
+		r.load()
 	}
 
-	logFetcher := newFetcherOrDie(*logBaseURL)
 	logHasher := rfc6962.DefaultHasher
 	logVerifier := verifierOrDie(*logPubKeyFile, "log")
-	lst, err := client.NewLogStateTracker(
-		ctx,
-		logFetcher,
-		logHasher,
-		nil,
-		logVerifier,
-		*logOrigin,
-		client.UnilateralConsensus(logFetcher),
-	)
-	if _, _, _, err := lst.Update(ctx); err != nil {
-		klog.Exitf("Update: %v", err)
-	}
-
-	idx, err := client.LookupIndex(ctx, logFetcher, logHasher.HashLeaf(manifest))
+	lc := newLogClientOrDie(*logType, *logBaseURL, *logOrigin, logVerifier)
+	cpRaw, cp, err := lc.Checkpoint(ctx)
 	if err != nil {
-		klog.Exitf("LookupIndex: %v", err)
+		klog.Exitf("Checkpoint: %v", err)
 	}
-	klog.Infof("Found manifest at index %d", idx)
 
-	incP, err := lst.ProofBuilder.InclusionProof(ctx, idx)
-	if err != nil {
-		klog.Exitf("InclusionProof: %v", err)
-	}
+	// maxIndex is the index which the checkpoint must commit to for all
+	// releases to be included.
+	maxIndex := uint64(0)
 
-	bundle := firmware.Bundle{
-		Checkpoint:     lst.LatestConsistentRaw,
-		Index:          idx,
-		InclusionProof: incP,
-		Manifest:       manifest,
-		Firmware:       fwBin,
-	}
-	v := firmware.BundleVerifier{
-		LogOrigin:         *logOrigin,
-		LogVerifer:        logVerifier,
-		ManifestVerifiers: []note.Verifier{mv},
+	for _, r := range releases {
+		if r.index, err = lc.LookupIndex(ctx, logHasher.HashLeaf(r.manifest), cp.Size); err != nil {
+			klog.Exitf("LookupIndex(%q): %v", r.ManifestFile, err)
+		}
+		klog.Infof("Found manifest %q at index %d", r.ManifestFile, r.index)
+
+		if r.index > maxIndex {
+			maxIndex = r.index
+		}
 	}
-	if _, err := v.Verify(bundle); err != nil {
-		klog.Exitf("Failed to verify proof bundle: %v", err)
+
+	policy := witnessPolicyOrDie(*witnessKeysFile, *witnessThreshold)
+
+	if len(*checkpointURL) > 0 {
+		// Build the bundles against the cosigned checkpoint, which may
+		// differ from the latest one served by the log.
+		if cpRaw, cp, err = cosignedCheckpoint(ctx, *checkpointURL, *logOrigin, logVerifier, policy, maxIndex, *witnessTimeout); err != nil {
+			klog.Exitf("Cosigned checkpoint: %v", err)
+		}
+		klog.Infof("Using cosigned checkpoint of size %d", cp.Size)
+	} else if err := policy.Verify(cpRaw); err != nil {
+		klog.Exitf("Log checkpoint does not satisfy the witness policy: %v", err)
 	}
 
-	// We don't want the firmware in the encoded config, we only
-	// needed it to verify the bundle above.
-	bundle.Firmware = nil
-	jsn, _ := json.MarshalIndent(&bundle, "", " ")
-	klog.Infof("ProofBundle:\n%s", string(jsn))
+	for _, r := range releases {
+		incP, err := lc.InclusionProof(ctx, r.index, *cp)
+		if err != nil {
+			klog.Exitf("InclusionProof(%q): %v", r.ManifestFile, err)
+		}
+
+		// The manifest commits to the decompressed firmware image.
+		fw, err := decompressFirmware(r.firmware, r.release.Component)
+		if err != nil {
+			klog.Exitf("Invalid firmware %q: %v", r.FirmwareFile, err)
+		}
+
+		bundle := firmware.Bundle{
+			Checkpoint:     cpRaw,
+			Index:          r.index,
+			InclusionProof: incP,
+			Manifest:       r.manifest,
+			Firmware:       fw,
+		}
+		v := firmware.BundleVerifier{
+			LogOrigin:         *logOrigin,
+			LogVerifer:        logVerifier,
+			ManifestVerifiers: r.verifier,
+		}
+		if _, err := v.Verify(bundle); err != nil {
+			klog.Exitf("Failed to verify proof bundle for %q: %v", r.ManifestFile, err)
+		}
+
+		// We don't want the firmware in the encoded config, we only
+		// needed it to verify the bundle above.
+		bundle.Firmware = nil
+		jsn, _ := json.MarshalIndent(&bundle, "", " ")
+		klog.Infof("ProofBundle:\n%s", string(jsn))
 
-	b := &bytes.Buffer{}
-	enc := gob.NewEncoder(b)
-	if enc.Encode(bundle); err != nil {
-		klog.Exitf("Failed to encode bundle: %v", err)
+		pb := api.NewProofBundle(&bundle)
+		if r.bundle, err = pb.Bytes(); err != nil {
+			klog.Exitf("Failed to encode bundle: %v", err)
+		}
+
+		if len(r.PackageFile) > 0 {
+			r.pkg = r.packageOrDie(pb)
+		}
 	}
 
-	if err := os.WriteFile(*outputFile, b.Bytes(), 0o644); err != nil {
-		klog.Exitf("WriteFile: %v", err)
+	// Bundles are only written once all have been built, so that a batch
+	// is never left partially updated.
+	for _, r := range releases {
+		if err := os.WriteFile(r.OutputFile, r.bundle, 0o644); err != nil {
+			klog.Exitf("WriteFile: %v", err)
+		}
+
+		klog.Infof("Wrote %d bytes of proof bundle to %q", len(r.bundle), r.OutputFile)
+
+		if len(r.pkg) == 0 {
+			continue
+		}
+
+		if err := os.WriteFile(r.PackageFile, r.pkg, 0o644); err != nil {
+			klog.Exitf("WriteFile: %v", err)
+		}
+
+		klog.Infof("Wrote %d bytes of update package to %q", len(r.pkg), r.PackageFile)
 	}
 
-	klog.Infof("Wrote %d bytes of proof bundle to %q", b.Len(), *outputFile)
+	return cpRaw, cp
 }
 
-// newFetcherOrDie creates a Fetcher for the log at the given root location.
-func newFetcherOrDie(logURL string) client.Fetcher {
-	root, err := url.Parse(logURL)
-	if err != nil {
-		klog.Exitf("Couldn't parse log_url: %v", err)
+// packageOrDie returns the serialized update package for the release, with
+// the given proof bundle.
+//
+// When a delta base is set the package firmware is a binary delta, against
+// the delta base image, which devices reconstruct the firmware image from.
+func (r *release) packageOrDie(pb *api.ProofBundle) []byte {
+	t := api.FirmwareType_APPLET
+	if r.release.Component == ftlog.ComponentOS {
+		t = api.FirmwareType_OS
 	}
 
-	get := getByScheme[root.Scheme]
-	if get == nil {
-		klog.Exitf("Unsupported URL scheme %s", root.Scheme)
+	p := &api.UpdatePackage{
+		Type:     t,
+		Version:  r.release.Git.TagName.String(),
+		Firmware: r.firmware,
+		Proof:    pb,
 	}
 
-	r := func(ctx context.Context, p string) ([]byte, error) {
-		u, err := root.Parse(p)
+	if len(r.DeltaBaseFile) > 0 {
+		base, err := os.ReadFile(r.DeltaBaseFile)
 		if err != nil {
-			return nil, err
+			klog.Exitf("Failed to read delta base %q: %v", r.DeltaBaseFile, err)
 		}
-		return get(ctx, u)
+
+		p.Firmware = delta.Diff(base, r.firmware)
+		if p.DeltaBase, err = delta.Base(p.Firmware); err != nil {
+			klog.Exitf("Failed to build delta: %v", err)
+		}
+
+		klog.Infof("Built %d bytes delta against %q", len(p.Firmware), r.DeltaBaseFile)
 	}
-	return r
-}
 
-var getByScheme = map[string]func(context.Context, *url.URL) ([]byte, error){
-	"http":  readHTTP,
-	"https": readHTTP,
-	"file": func(_ context.Context, u *url.URL) ([]byte, error) {
-		return os.ReadFile(u.Path)
-	},
-}
+	h := sha256.Sum256(p.Firmware)
+	p.FirmwareSHA256 = h[:]
 
-func readHTTP(ctx context.Context, u *url.URL) ([]byte, error) {
-	req, err := http.NewRequest("GET", u.String(), nil)
+	buf, err := p.Bytes()
 	if err != nil {
-		return nil, err
+		klog.Exitf("Failed to encode update package: %v", err)
 	}
-	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
-	if err != nil {
-		return nil, err
-	}
-	switch resp.StatusCode {
-	case http.StatusNotFound:
-		klog.Infof("Not found: %q", u.String())
-		return nil, os.ErrNotExist
-	case http.StatusOK:
-		break
-	default:
-		return nil, fmt.Errorf("unexpected http status %q", resp.Status)
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			klog.Errorf("resp.Body.Close(): %v", err)
-		}
-	}()
-	return io.ReadAll(resp.Body)
+
+	return buf
 }
 
 func verifierOrDie(p string, thing string) note.Verifier {
@@ -187,12 +328,42 @@ func verifierOrDie(p string, thing string) note.Verifier {
 	return v
 }
 
-func loadManifestOrDie(p string, v note.Verifier) ([]byte, ftlog.FirmwareRelease) {
-	b, err := os.ReadFile(p)
-	if err != nil {
-		klog.Exitf("Failed to read manifest %q: %v", p, err)
+// componentOrDie returns the manifest component name for the given firmware
+// type.
+func componentOrDie(t string) string {
+	switch strings.ToLower(t) {
+	case "applet":
+		return ftlog.ComponentApplet
+	case "os":
+		return ftlog.ComponentOS
 	}
-	n, err := note.Open(b, note.VerifierList(v))
+	klog.Exitf("Unknown firmware type %q", t)
+	return ""
+}
+
+// manifestVerifiersOrDie loads the manifest verifiers from the given files,
+// and returns them along with the number of signatures required from them.
+func manifestVerifiersOrDie(files []string, threshold int) ([]note.Verifier, int) {
+	mvs := []note.Verifier{}
+	for _, f := range files {
+		mvs = append(mvs, verifierOrDie(f, "manifest"))
+	}
+	if threshold == 0 {
+		threshold = len(mvs)
+	}
+	if err := quorum.Check(threshold, len(mvs)); err != nil {
+		klog.Exitf("Invalid manifest threshold: %v", err)
+	}
+	return mvs, threshold
+}
+
+// verifyManifestOrDie verifies that a manifest is signed by at least
+// threshold of the given verifiers and is for the given component.
+//
+// The verifiers which signed the manifest are returned, as these are the ones
+// the bundle is verified with, like the OS does.
+func verifyManifestOrDie(b []byte, vs []note.Verifier, threshold int, component string) (ftlog.FirmwareRelease, []note.Verifier) {
+	n, signing, err := quorum.Open(b, vs, threshold)
 	if err != nil {
 		klog.Exitf("Failed to verify manifest: %v", err)
 	}
@@ -200,5 +371,9 @@ func loadManifestOrDie(p string, v note.Verifier) ([]byte, ftlog.FirmwareRelease
 	if err := json.Unmarshal([]byte(n.Text), &fr); err != nil {
 		klog.Exitf("Invalid manifest contents %q: %v", n.Text, err)
 	}
-	return b, fr
+	if fr.Component != component {
+		klog.Exitf("Manifest is for component %q, want %q", fr.Component, component)
+	}
+
+	return fr, signing
 }
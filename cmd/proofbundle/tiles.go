@@ -0,0 +1,171 @@
+// Copyright 2026 The Armored Witness authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strconv"
+
+	fmtlog "github.com/transparency-dev/formats/log"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/serverless-log/client"
+	"golang.org/x/mod/sumdb/note"
+	"golang.org/x/mod/sumdb/tlog"
+)
+
+// tileHeight is the fixed tile height of C2SP tlog-tiles logs.
+const tileHeight = 8
+
+// tiledLog is a logClient for logs using the C2SP tlog-tiles layout
+// (https://c2sp.org/tlog-tiles).
+type tiledLog struct {
+	f      client.Fetcher
+	origin string
+	v      note.Verifier
+}
+
+func (l *tiledLog) Checkpoint(ctx context.Context) ([]byte, *fmtlog.Checkpoint, error) {
+	cp, raw, _, err := client.FetchCheckpoint(ctx, l.f, l.v, l.origin)
+	return raw, cp, err
+}
+
+// LookupIndex scans the log entry bundles, as tiled logs do not provide an
+// index by leaf hash.
+func (l *tiledLog) LookupIndex(ctx context.Context, leafHash []byte, size uint64) (uint64, error) {
+	for n := uint64(0); n*(1<<tileHeight) < size; n++ {
+		t := tlog.Tile{H: tileHeight, L: -1, N: int64(n), W: 1 << tileHeight}
+		if r := size - n*(1<<tileHeight); r < 1<<tileHeight {
+			t.W = int(r)
+		}
+
+		b, err := fetchTile(ctx, l.f, t)
+		if err != nil {
+			return 0, fmt.Errorf("failed to fetch entry bundle %d: %v", n, err)
+		}
+
+		for i := 0; i < t.W; i++ {
+			if len(b) < 2 {
+				return 0, fmt.Errorf("entry bundle %d is truncated", n)
+			}
+			size := int(binary.BigEndian.Uint16(b))
+			if len(b) < 2+size {
+				return 0, fmt.Errorf("entry bundle %d is truncated", n)
+			}
+
+			if bytes.Equal(rfc6962.DefaultHasher.HashLeaf(b[2:2+size]), leafHash) {
+				return n*(1<<tileHeight) + uint64(i), nil
+			}
+
+			b = b[2+size:]
+		}
+	}
+
+	return 0, fmt.Errorf("leaf hash %x not found: %w", leafHash, os.ErrNotExist)
+}
+
+// InclusionProof builds the proof from the log tiles, which are verified
+// against the checkpoint.
+func (l *tiledLog) InclusionProof(ctx context.Context, index uint64, cp fmtlog.Checkpoint) ([][]byte, error) {
+	tree := tlog.Tree{N: int64(cp.Size)}
+	copy(tree.Hash[:], cp.Hash)
+
+	r := tlog.TileHashReader(tree, &tileReader{ctx: ctx, f: l.f})
+
+	p, err := tlog.ProveRecord(int64(cp.Size), int64(index), r)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := make([][]byte, 0, len(p))
+	for _, h := range p {
+		proof = append(proof, bytes.Clone(h[:]))
+	}
+
+	return proof, nil
+}
+
+// tileReader implements tlog.TileReader for C2SP tlog-tiles logs.
+type tileReader struct {
+	ctx context.Context
+	f   client.Fetcher
+}
+
+func (r *tileReader) Height() int {
+	return tileHeight
+}
+
+func (r *tileReader) ReadTiles(tiles []tlog.Tile) ([][]byte, error) {
+	data := make([][]byte, len(tiles))
+
+	for i, t := range tiles {
+		b, err := fetchTile(r.ctx, r.f, t)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch tile %s: %v", tilePath(t), err)
+		}
+		if len(b) < t.W*tlog.HashSize {
+			return nil, fmt.Errorf("tile %s has invalid length %d", tilePath(t), len(b))
+		}
+		data[i] = b[:t.W*tlog.HashSize]
+	}
+
+	return data, nil
+}
+
+func (r *tileReader) SaveTiles(_ []tlog.Tile, _ [][]byte) {}
+
+// fetchTile fetches a tile, partial tiles which are no longer available are
+// fetched in full as logs only retain them until the tile is complete. The
+// caller must truncate full tiles to the requested width.
+func fetchTile(ctx context.Context, f client.Fetcher, t tlog.Tile) ([]byte, error) {
+	b, err := f(ctx, tilePath(t))
+	if err != nil && t.W < 1<<t.H {
+		t.W = 1 << t.H
+		if full, fullErr := f(ctx, tilePath(t)); fullErr == nil {
+			return full, nil
+		}
+	}
+
+	return b, err
+}
+
+// tilePath returns the C2SP tlog-tiles path of a tile, level -1 tiles are
+// entry bundles.
+func tilePath(t tlog.Tile) string {
+	l := strconv.Itoa(t.L)
+	if t.L == -1 {
+		l = "entries"
+	}
+
+	p := fmt.Sprintf("tile/%s/%s", l, tileIndex(uint64(t.N)))
+	if t.W < 1<<t.H {
+		p += fmt.Sprintf(".p/%d", t.W)
+	}
+
+	return p
+}
+
+// tileIndex encodes a tile index as path elements of three digits, all but
+// the last prefixed with x.
+func tileIndex(n uint64) string {
+	s := fmt.Sprintf("%03d", n%1000)
+	for n >= 1000 {
+		n /= 1000
+		s = fmt.Sprintf("x%03d/%s", n%1000, s)
+	}
+	return s
+}
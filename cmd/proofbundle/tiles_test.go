@@ -0,0 +1,243 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/transparency-dev/armored-witness-common/release/firmware"
+	"github.com/transparency-dev/armored-witness-common/release/firmware/ftlog"
+	fmtlog "github.com/transparency-dev/formats/log"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"golang.org/x/mod/sumdb/note"
+	"golang.org/x/mod/sumdb/tlog"
+)
+
+const testOrigin = "example.com/test-log"
+
+func testKeys(t *testing.T, name string) (note.Signer, note.Verifier) {
+	t.Helper()
+
+	skey, vkey, err := note.GenerateKey(rand.Reader, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := note.NewSigner(skey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := note.NewVerifier(vkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, v
+}
+
+// writeFile writes a file, creating its parent directories, within dir.
+func writeFile(t *testing.T, dir string, p string, data []byte) {
+	t.Helper()
+
+	p = filepath.Join(dir, filepath.FromSlash(p))
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(p, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeTiledLog writes a C2SP tlog-tiles log of the given entries to dir,
+// with its checkpoint signed by s, only the tiles of the final tree are
+// written.
+func writeTiledLog(t *testing.T, dir string, s note.Signer, entries [][]byte) {
+	t.Helper()
+
+	var hashes []tlog.Hash
+
+	r := tlog.HashReaderFunc(func(indexes []int64) ([]tlog.Hash, error) {
+		h := make([]tlog.Hash, len(indexes))
+		for i, idx := range indexes {
+			h[i] = hashes[idx]
+		}
+		return h, nil
+	})
+
+	for i, e := range entries {
+		h, err := tlog.StoredHashes(int64(i), e, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, h...)
+	}
+
+	n := int64(len(entries))
+
+	for _, tile := range tlog.NewTiles(tileHeight, 0, n) {
+		data, err := tlog.ReadTileData(tile, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, dir, tilePath(tile), data)
+
+		if tile.L != 0 {
+			continue
+		}
+
+		// entry bundles match the level 0 tiles
+		var bundle []byte
+		for _, e := range entries[tile.N<<tileHeight : tile.N<<tileHeight+int64(tile.W)] {
+			bundle = binary.BigEndian.AppendUint16(bundle, uint16(len(e)))
+			bundle = append(bundle, e...)
+		}
+		tile.L = -1
+		writeFile(t, dir, tilePath(tile), bundle)
+	}
+
+	root, err := tlog.TreeHash(n, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cp := fmtlog.Checkpoint{
+		Origin: testOrigin,
+		Size:   uint64(n),
+		Hash:   root[:],
+	}
+
+	signed, err := note.Sign(&note.Note{Text: string(cp.Marshal())}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, dir, "checkpoint", signed)
+}
+
+func TestTiledLog(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	logSigner, logVerifier := testKeys(t, "log")
+	manifestSigner, manifestVerifier := testKeys(t, "manifest")
+
+	fw := []byte("firmware image")
+	h := sha256.Sum256(fw)
+
+	text, err := json.Marshal(&ftlog.FirmwareRelease{
+		Component: ftlog.ComponentApplet,
+		Git:       ftlog.Git{TagName: *semver.New("1.2.3")},
+		Output:    ftlog.Output{FirmwareDigestSha256: h[:]},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := note.Sign(&note.Note{Text: string(text) + "\n"}, manifestSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// more entries than a full tile, so that partial tiles are also
+	// fetched, with the manifest in the partial one
+	const size, index = 300, 270
+
+	entries := make([][]byte, size)
+	for i := range entries {
+		entries[i] = []byte(fmt.Sprintf("entry %d", i))
+	}
+	entries[index] = manifest
+
+	writeTiledLog(t, dir, logSigner, entries)
+
+	l := &tiledLog{
+		f:      newFetcherOrDie("file://" + filepath.ToSlash(dir) + "/"),
+		origin: testOrigin,
+		v:      logVerifier,
+	}
+
+	raw, cp, err := l.Checkpoint(ctx)
+	if err != nil {
+		t.Fatalf("Checkpoint(): %v", err)
+	}
+
+	if cp.Size != size {
+		t.Fatalf("Checkpoint() size = %d, want %d", cp.Size, size)
+	}
+
+	i, err := l.LookupIndex(ctx, rfc6962.DefaultHasher.HashLeaf(manifest), cp.Size)
+	if err != nil {
+		t.Fatalf("LookupIndex(): %v", err)
+	}
+
+	if i != index {
+		t.Fatalf("LookupIndex() = %d, want %d", i, index)
+	}
+
+	if _, err := l.LookupIndex(ctx, rfc6962.DefaultHasher.HashLeaf([]byte("missing")), cp.Size); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LookupIndex(missing) = %v, want %v", err, os.ErrNotExist)
+	}
+
+	proof, err := l.InclusionProof(ctx, i, *cp)
+	if err != nil {
+		t.Fatalf("InclusionProof(): %v", err)
+	}
+
+	v := firmware.BundleVerifier{
+		LogOrigin:         testOrigin,
+		LogVerifer:        logVerifier,
+		ManifestVerifiers: []note.Verifier{manifestVerifier},
+	}
+
+	b := firmware.Bundle{
+		Checkpoint:     raw,
+		Index:          i,
+		InclusionProof: proof,
+		Manifest:       manifest,
+		Firmware:       fw,
+	}
+
+	if _, err := v.Verify(b); err != nil {
+		t.Errorf("Verify(): %v", err)
+	}
+}
+
+func TestTilePath(t *testing.T) {
+	for _, test := range []struct {
+		tile tlog.Tile
+		want string
+	}{
+		{tile: tlog.Tile{H: tileHeight, L: 0, N: 0, W: 256}, want: "tile/0/000"},
+		{tile: tlog.Tile{H: tileHeight, L: 1, N: 1234067, W: 256}, want: "tile/1/x001/x234/067"},
+		{tile: tlog.Tile{H: tileHeight, L: -1, N: 1, W: 44}, want: "tile/entries/001.p/44"},
+	} {
+		if got := tilePath(test.tile); got != test.want {
+			t.Errorf("tilePath(%v) = %q, want %q", test.tile, got, test.want)
+		}
+	}
+}
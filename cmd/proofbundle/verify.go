@@ -0,0 +1,87 @@
+// Copyright 2026 The Armored Witness authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/transparency-dev/armored-witness-common/release/firmware"
+	"github.com/transparency-dev/armored-witness-common/release/firmware/ftlog"
+	"github.com/transparency-dev/armored-witness-os/api"
+	"github.com/transparency-dev/armored-witness-os/internal/compress"
+	"k8s.io/klog"
+)
+
+// verify verifies a proof bundle and firmware image offline, with the same
+// firmware.BundleVerifier checks performed by the OS.
+func verify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	bundleFile := fs.String("bundle_file", "", "Proof bundle to verify.")
+	firmwareFile := fs.String("firmware_file", "", "Firmware image to verify.")
+	firmwareType := fs.String("firmware_type", "applet", "Type of firmware to verify (applet or os).")
+	logOrigin := fs.String("log_origin", "", "FT log origin string.")
+	logPubKeyFile := fs.String("log_pubkey_file", "", "File containing the FT log's public key in Note verifier format.")
+	manifestThreshold := fs.Int("manifest_threshold", 0, "Number of manifest public keys which must have signed the manifest, all keys are required when unset.")
+	var manifestPubKeyFiles stringsFlag
+	fs.Var(&manifestPubKeyFiles, "manifest_pubkey_file", "File containing a Note verifier string to verify manifest signatures, can be repeated.")
+	fs.Parse(args)
+
+	component := componentOrDie(*firmwareType)
+	mvs, threshold := manifestVerifiersOrDie(manifestPubKeyFiles, *manifestThreshold)
+	logVerifier := verifierOrDie(*logPubKeyFile, "log")
+
+	b, err := os.ReadFile(*bundleFile)
+	if err != nil {
+		klog.Exitf("Failed to read bundle %q: %v", *bundleFile, err)
+	}
+	pb, err := api.ParseProofBundle(b)
+	if err != nil {
+		klog.Exitf("Failed to parse bundle %q: %v", *bundleFile, err)
+	}
+
+	fwBin, err := os.ReadFile(*firmwareFile)
+	if err != nil {
+		klog.Exitf("Failed to read firmware %q: %v", *firmwareFile, err)
+	}
+	if fwBin, err = decompressFirmware(fwBin, component); err != nil {
+		klog.Exitf("Invalid firmware %q: %v", *firmwareFile, err)
+	}
+
+	fr, mvs := verifyManifestOrDie(pb.Manifest, mvs, threshold, component)
+
+	v := firmware.BundleVerifier{
+		LogOrigin:         *logOrigin,
+		LogVerifer:        logVerifier,
+		ManifestVerifiers: mvs,
+	}
+	if _, err := v.Verify(pb.Bundle(fwBin)); err != nil {
+		klog.Exitf("Failed to verify proof bundle: %v", err)
+	}
+
+	fmt.Printf("OK: %s %s (%x) at index %d\n", fr.Component, fr.Git.TagName.String(), fr.Output.FirmwareDigestSha256, pb.LogIndex)
+}
+
+// decompressFirmware returns gzip compressed applet images decompressed, as the
+// manifest commits to the decompressed image, the OS does not support
+// compressed images.
+func decompressFirmware(b []byte, component string) ([]byte, error) {
+	if compress.IsCompressed(b) && component != ftlog.ComponentApplet {
+		return nil, fmt.Errorf("compressed %s images are not supported", component)
+	}
+
+	return compress.Decompress(b)
+}
@@ -0,0 +1,117 @@
+// Copyright 2026 The Armored Witness authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/transparency-dev/armored-witness-os/internal/witness"
+	fmtlog "github.com/transparency-dev/formats/log"
+	"golang.org/x/mod/sumdb/note"
+	"k8s.io/klog"
+)
+
+// witnessPollInterval is the delay between attempts to fetch a checkpoint
+// with enough witness cosignatures.
+const witnessPollInterval = 10 * time.Second
+
+// witnessPolicyOrDie returns the witness policy from a file of witness keys,
+// one per line in note or cosignature/v1 format, requiring threshold
+// cosignatures from them, or all of them if threshold is zero.
+func witnessPolicyOrDie(p string, threshold int) *witness.Policy {
+	if len(p) == 0 {
+		return &witness.Policy{}
+	}
+
+	keys, err := os.ReadFile(p)
+	if err != nil {
+		klog.Exitf("Failed to read witness keys file %q: %v", p, err)
+	}
+
+	policy, err := witness.ParsePolicy(string(keys), "")
+	if err != nil {
+		klog.Exitf("Invalid witness keys: %v", err)
+	}
+
+	if threshold == 0 {
+		threshold = len(policy.Verifiers)
+	}
+	if threshold < 1 || threshold > len(policy.Verifiers) {
+		klog.Exitf("Invalid witness threshold %d for %d witness keys", threshold, len(policy.Verifiers))
+	}
+	policy.Threshold = threshold
+
+	return policy
+}
+
+// cosignedCheckpoint fetches the checkpoint at the given URL, for example from
+// a witness or distributor, which must be signed by the log, satisfy the
+// witness policy and commit to the leaf at the given index.
+//
+// Fetch errors, and checkpoints failing the witness policy or too small, are
+// retried until the timeout expires.
+func cosignedCheckpoint(ctx context.Context, cpURL string, origin string, logVerifier note.Verifier, policy *witness.Policy, index uint64, timeout time.Duration) ([]byte, *fmtlog.Checkpoint, error) {
+	u, err := url.Parse(cpURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid checkpoint URL: %v", err)
+	}
+
+	get := getByScheme[u.Scheme]
+	if get == nil {
+		return nil, nil, fmt.Errorf("unsupported URL scheme %s", u.Scheme)
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	// Bound the fetches themselves, so that a stalled server cannot
+	// extend the wait beyond the timeout.
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	for {
+		raw, err := get(ctx, u)
+		if err != nil {
+			// Fetch errors, such as network or server errors, may
+			// be transient and are retried.
+			err = fmt.Errorf("failed to fetch checkpoint: %v", err)
+		} else {
+			var cp *fmtlog.Checkpoint
+			if cp, _, _, err = fmtlog.ParseCheckpoint(raw, origin, logVerifier); err != nil {
+				return nil, nil, fmt.Errorf("invalid checkpoint: %v", err)
+			}
+
+			if cp.Size <= index {
+				err = fmt.Errorf("checkpoint size %d does not include index %d", cp.Size, index)
+			} else if err = policy.Verify(raw); err == nil {
+				return raw, cp, nil
+			}
+		}
+
+		if time.Now().Add(witnessPollInterval).After(deadline) {
+			return nil, nil, err
+		}
+
+		klog.Infof("Waiting for cosigned checkpoint: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(witnessPollInterval):
+		}
+	}
+}
@@ -19,16 +19,21 @@ package main
 
 import (
 	"compress/gzip"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"strings"
+	"time"
 
+	"github.com/coreos/go-semver/semver"
 	flynn_hid "github.com/flynn/hid"
 	"github.com/flynn/u2f/u2fhid"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/transparency-dev/armored-witness-common/release/firmware/ftlog"
 	"github.com/transparency-dev/armored-witness-os/api"
 )
 
@@ -38,7 +43,7 @@ const maxChunkSize = api.MaxMessageSize - 64
 func confirm(msg string) bool {
 	var res string
 
-	fmt.Printf("%s (y/n): ", msg)
+	fmt.Fprintf(log.Writer(), "%s (y/n): ", msg)
 	fmt.Scanln(&res)
 
 	return res == "y"
@@ -62,21 +67,237 @@ func (d Device) status() (s *api.Status, err error) {
 	return
 }
 
-func (d Device) hab() error {
-	buf, err := d.u2f.Command(api.U2FHID_ARMORY_HAB, nil)
+// command sends a vendor specific command expecting an api.Response, the
+// response payload is returned if no error was reported by the device.
+func (d Device) command(cmd byte, req []byte) ([]byte, error) {
+	buf, err := d.u2f.Command(cmd, req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	res := &api.Response{}
 	if err := proto.Unmarshal(buf, res); err != nil {
-		return err
+		return nil, err
 	}
 	if res.Error != api.ErrorCode_NONE {
-		return fmt.Errorf("%v: %s", res.Error, res.Payload)
+		return nil, &rejectedError{code: res.Error, msg: string(res.Payload)}
+	}
+	return res.Payload, nil
+}
+
+func (d Device) hab() error {
+	_, err := d.command(api.U2FHID_ARMORY_HAB, nil)
+	return err
+}
+
+func (d Device) factoryReset() error {
+	_, err := d.command(api.U2FHID_ARMORY_FACTORY_RESET, nil)
+	return err
+}
+
+func (d Device) switchAppletSlot() error {
+	_, err := d.command(api.U2FHID_ARMORY_RECOVERY_SWITCH_SLOT, nil)
+	return err
+}
+
+// recoveryInstall sends an applet firmware image, in chunks, followed by its
+// proof bundle to a device in recovery mode. The deltaBase, if set, indicates
+// that the image is a binary delta against the installed applet.
+func (d Device) recoveryInstall(elf []byte, proofBundle []byte, deltaBase []byte) error {
+	return d.sendFirmware(api.U2FHID_ARMORY_RECOVERY_INSTALL, api.FirmwareType_APPLET, elf, proofBundle, deltaBase)
+}
+
+// installTimeout is the time allowed for a device to install the firmware it
+// has been sent, and to reboot into it.
+const installTimeout = 5 * time.Minute
+
+// install sends a firmware image, in chunks, followed by its proof bundle and
+// waits for the device to install it and reboot into the released firmware.
+func (d Device) install(t api.FirmwareType, fw []byte, proofBundle []byte, deltaBase []byte, release *ftlog.FirmwareRelease) error {
+	s, err := d.status()
+	if err != nil {
+		return fmt.Errorf("failed to get status: %w", err)
+	}
+	serial := s.Serial
+
+	if err := d.sendFirmware(api.U2FHID_ARMORY_OTA, t, fw, proofBundle, deltaBase); err != nil {
+		return err
+	}
+
+	log.Printf("%s: firmware sent, waiting for installation", d.usb.Path)
+
+	deadline := time.Now().Add(installTimeout)
+	disconnected := false
+
+	for {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("installation not completed after %v", installTimeout)
+		}
+
+		time.Sleep(time.Second)
+
+		s, err := d.otaStatus(t)
+		if err != nil {
+			var re *rejectedError
+			if errors.As(err, &re) {
+				return err
+			}
+			// The device disconnects as it reboots into the update,
+			// but it might as well have crashed or been unplugged.
+			disconnected = true
+			break
+		}
+
+		if len(s.Error) > 0 {
+			return &rejectedError{code: api.ErrorCode_GENERIC_ERROR, msg: s.Error}
+		}
+
+		if !s.Installing {
+			break
+		}
+	}
+
+	log.Printf("%s: firmware installed, waiting for reboot", d.usb.Path)
+
+	return waitInstalled(serial, d.usb.Path, t, release, disconnected, deadline)
+}
+
+// waitInstalled waits until the device, identified by its serial number, or
+// HID path when unavailable, reports running the released firmware once
+// rebooted.
+//
+// Applets are verified after boot, an applet install is therefore only
+// confirmed once the device reports the applet version as loaded.
+func waitInstalled(serial string, path string, t api.FirmwareType, release *ftlog.FirmwareRelease, disconnected bool, deadline time.Time) (err error) {
+	for time.Now().Before(deadline) {
+		var s *api.Status
+
+		if s, err = find(serial, path); err != nil {
+			disconnected = true
+			time.Sleep(time.Second)
+			continue
+		}
+
+		switch {
+		case !disconnected:
+			err = errors.New("device did not reboot")
+		case s.Recovery:
+			return fmt.Errorf("device entered recovery mode: %s", s.RecoveryReason)
+		case t == api.FirmwareType_OS:
+			err = checkRunning(s, release)
+		case len(s.AppletVersion) == 0:
+			err = errors.New("applet not loaded")
+		default:
+			err = checkVersion(s.AppletVersion, release)
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		time.Sleep(time.Second)
+	}
+
+	return fmt.Errorf("firmware not running after %v: %w", installTimeout, err)
+}
+
+// checkRunning returns an error unless the status reports the OS release as
+// running.
+func checkRunning(s *api.Status, release *ftlog.FirmwareRelease) error {
+	if err := checkVersion(s.Version, release); err != nil {
+		return err
 	}
+
+	// The runtime is reported as "go<version> GOOS/GOARCH".
+	if runtime, _, _ := strings.Cut(s.Runtime, " "); runtime != "go"+release.Build.TamagoVersion.String() {
+		return fmt.Errorf("running runtime %q, want go%v", runtime, release.Build.TamagoVersion)
+	}
+
 	return nil
 }
 
+// checkVersion returns an error unless the running version is the release
+// one.
+func checkVersion(running string, release *ftlog.FirmwareRelease) error {
+	v, err := semver.NewVersion(strings.TrimPrefix(running, "v"))
+	if err != nil {
+		return fmt.Errorf("invalid running version %q: %v", running, err)
+	}
+
+	if !v.Equal(release.Git.TagName) {
+		return fmt.Errorf("running version %v, want %v", v, release.Git.TagName)
+	}
+
+	return nil
+}
+
+// sendFirmware sends a firmware image, in chunks, followed by its proof
+// bundle using the given command, reporting progress along the way.
+func (d Device) sendFirmware(cmd byte, t api.FirmwareType, fw []byte, proofBundle []byte, deltaBase []byte) error {
+	// leave room for the remaining update fields
+	chunkSize := maxChunkSize - 64
+
+	u := &api.FirmwareUpdate{Type: t}
+
+	for off := 0; off < len(fw); off += chunkSize {
+		end := off + chunkSize
+		if end > len(fw) {
+			end = len(fw)
+		}
+
+		h := sha256.Sum256(fw[off:end])
+		u.Offset = int64(off)
+		u.Image = fw[off:end]
+		u.Digest = h[:]
+
+		req, err := proto.Marshal(u)
+		if err != nil {
+			return err
+		}
+
+		if _, err := d.command(cmd, req); err != nil {
+			return fmt.Errorf("chunk %d: %w", u.Sequence, err)
+		}
+
+		log.Printf("%s: sent %d/%d bytes (%d%%)", d.usb.Path, end, len(fw), end*100/len(fw))
+		u.Sequence++
+	}
+
+	req, err := proto.Marshal(&api.FirmwareUpdate{
+		Sequence:    u.Sequence,
+		Offset:      int64(len(fw)),
+		ProofBundle: proofBundle,
+		DeltaBase:   deltaBase,
+		Type:        t,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = d.command(cmd, req)
+	return err
+}
+
+// otaStatus returns the progress of the update, of the given firmware type,
+// sent to the device.
+func (d Device) otaStatus(t api.FirmwareType) (*api.FirmwareUpdateStatus, error) {
+	req, err := proto.Marshal(&api.FirmwareUpdate{Type: t})
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := d.command(api.U2FHID_ARMORY_OTA_STATUS, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &api.FirmwareUpdateStatus{}
+	if err := proto.Unmarshal(buf, s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
 func (d Device) getLogMessages(cmd byte) (string, error) {
 	r, w := io.Pipe()
 	defer r.Close()
@@ -131,7 +352,8 @@ func (d Device) crashLogs() (string, error) {
 	return d.getLogMessages(api.U2FHID_ARMORY_CRASH_LOGS)
 }
 
-func (d Device) cfg(dhcp bool, ip string, mask string, gw string, dns string, ntp string) error {
+// checkCfg validates the configuration parameters for cfg().
+func checkCfg(ip string, mask string, gw string, dns string) error {
 	if len(ip) == 0 || len(gw) == 0 || len(dns) == 0 {
 		return errors.New("trusted applet IP, gatewy and DNS addresses must all be specified for configuration change (flags: -a -g -r)")
 	}
@@ -152,32 +374,27 @@ func (d Device) cfg(dhcp bool, ip string, mask string, gw string, dns string, nt
 		return fmt.Errorf("DNS address is invalid: %v", err)
 	}
 
-	c := &api.Configuration{
-		DHCP:      dhcp,
-		IP:        ip,
-		Netmask:   mask,
-		Gateway:   gw,
-		Resolver:  dns,
-		NTPServer: ntp,
-	}
+	return nil
+}
 
+func (d Device) cfg(c *api.Configuration) error {
 	log.Printf("sending configuration update to armored witness")
 
-	buf, err := d.u2f.Command(api.U2FHID_ARMORY_CFG, c.Bytes())
+	_, err := d.command(api.U2FHID_ARMORY_CFG, c.Bytes())
+	return err
+}
 
+// getCfg returns the current Trusted Applet configuration.
+func (d Device) getCfg() (*api.Configuration, error) {
+	buf, err := d.command(api.U2FHID_ARMORY_GET_CFG, nil)
 	if err != nil {
-		return err
-	}
-
-	res := &api.Response{}
-
-	if err = proto.Unmarshal(buf, res); err != nil {
-		return err
+		return nil, err
 	}
 
-	if res.Error != api.ErrorCode_NONE {
-		return fmt.Errorf("%+v", res)
+	c := &api.Configuration{}
+	if err := proto.Unmarshal(buf, c); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return c, nil
 }
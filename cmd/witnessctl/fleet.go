@@ -0,0 +1,112 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !tamago
+// +build !tamago
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// runFleet performs the command concurrently on all confirmed devices, each
+// subject to the per-device timeout, and records their outcome in rep.
+//
+// HID transfers cannot be interrupted, a device which times out is reported
+// as failed while its command is abandoned in the background. Mutating
+// commands are therefore only timed out when -timeout is explicitly given, as
+// abandoning them (e.g. mid-install) leaves the device state undetermined.
+func (c *Config) runFleet(cmd *command, rep *report, confirmed []bool) {
+	wg := sync.WaitGroup{}
+
+	timeout := c.timeout
+	if cmd.mutating && !c.set["timeout"] {
+		timeout = 0
+	}
+
+	for i, d := range c.devs {
+		if !confirmed[i] {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(i int, d Device) {
+			defer wg.Done()
+
+			res := make(chan *result, 1)
+			go func() {
+				res <- c.runDevice(cmd, d)
+			}()
+
+			var expired <-chan time.Time
+			if timeout > 0 {
+				expired = time.After(timeout)
+			}
+
+			select {
+			case r := <-res:
+				rep.Devices[i] = r
+			case <-expired:
+				rep.Devices[i].done(fmt.Errorf("timed out after %v", timeout))
+			}
+		}(i, d)
+	}
+
+	wg.Wait()
+}
+
+// outcome returns a short description of a command exit code.
+func outcome(code int) string {
+	switch code {
+	case exitOK:
+		return "ok"
+	case exitUsage:
+		return "cancelled"
+	case exitRejected:
+		return "rejected"
+	}
+
+	return "failed"
+}
+
+// printSummary prints a table of the command outcome on each device.
+func printSummary(rep *report) {
+	ok := 0
+
+	w := tabwriter.NewWriter(log.Writer(), 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "DEVICE\tSERIAL\tRESULT\tERROR")
+
+	for _, r := range rep.Devices {
+		if r.ExitCode == exitOK {
+			ok++
+		}
+
+		serial := r.Serial
+		if len(serial) == 0 {
+			serial = "-"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Path, serial, outcome(r.ExitCode), r.Error)
+	}
+
+	w.Flush()
+
+	log.Printf("%s succeeded on %d/%d devices", rep.Command, ok, len(rep.Devices))
+}
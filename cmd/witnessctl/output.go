@@ -0,0 +1,144 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !tamago
+// +build !tamago
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/transparency-dev/armored-witness-os/api"
+)
+
+// Exit codes, allowing scripts to tell failure causes apart. When a command
+// fails on several devices the highest exit code is used.
+const (
+	exitOK = 0
+	// exitUsage is used for invalid invocations and local errors (e.g.
+	// unreadable files or cancelled confirmations).
+	exitUsage = 1
+	// exitNoDevice is used when no witness device is found.
+	exitNoDevice = 2
+	// exitDeviceError is used when communication with a device fails.
+	exitDeviceError = 3
+	// exitRejected is used when a device reports an error for a command.
+	exitRejected = 4
+)
+
+// errCancelled is returned when the operator declines a confirmation.
+var errCancelled = errors.New("user cancelled")
+
+// rejectedError represents an error reported by a device in its response to
+// a command.
+type rejectedError struct {
+	code api.ErrorCode
+	msg  string
+}
+
+func (e *rejectedError) Error() string {
+	return fmt.Sprintf("%v: %s", e.code, e.msg)
+}
+
+// exitCode returns the exit code corresponding to a command error.
+func exitCode(err error) int {
+	var re *rejectedError
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errCancelled):
+		return exitUsage
+	case errors.As(err, &re):
+		return exitRejected
+	}
+
+	return exitDeviceError
+}
+
+// result represents the outcome of a command on a single device.
+type result struct {
+	// Path is the HID path of the device.
+	Path string `json:"path"`
+	// Serial is the device serial number, when it could be retrieved.
+	Serial string `json:"serial,omitempty"`
+	// Status is the device status, in protobuf JSON encoding.
+	Status json.RawMessage `json:"status,omitempty"`
+	// Config is the Trusted Applet configuration, in protobuf JSON
+	// encoding.
+	Config json.RawMessage `json:"config,omitempty"`
+	// Logs holds the retrieved console or crash logs.
+	Logs string `json:"logs,omitempty"`
+	// Message describes the outcome of commands which return no data.
+	Message string `json:"message,omitempty"`
+	// Error describes why the command failed.
+	Error    string `json:"error,omitempty"`
+	ExitCode int    `json:"exit_code"`
+
+	// text is the human readable command output.
+	text string
+}
+
+// done records the outcome of the command.
+func (r *result) done(err error) {
+	r.ExitCode = exitCode(err)
+
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// report represents the JSON output of a witnessctl invocation.
+type report struct {
+	Command string    `json:"command"`
+	Devices []*result `json:"devices,omitempty"`
+	// Error describes failures which prevented the command from running
+	// on any device.
+	Error    string `json:"error,omitempty"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// print writes the report, as JSON, to stdout.
+func (r *report) print() {
+	buf, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode report: %v", err)
+	}
+
+	fmt.Fprintf(os.Stdout, "%s\n", buf)
+}
+
+// fatal reports an error which prevents the command from running and exits
+// with the given code.
+func fatal(command string, code int, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+
+	if conf.json {
+		r := &report{
+			Command:  command,
+			Error:    msg,
+			ExitCode: code,
+		}
+		r.print()
+	} else {
+		log.Print(msg)
+	}
+
+	os.Exit(code)
+}
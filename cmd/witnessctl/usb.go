@@ -50,3 +50,39 @@ func detect() ([]Device, error) {
 
 	return devs, nil
 }
+
+// find returns the status of the connected witness device with the given
+// serial number, or with the given HID path if the serial number is empty.
+func find(serial string, path string) (*api.Status, error) {
+	devs, err := detect()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		for _, d := range devs {
+			d.u2f.Close()
+		}
+	}()
+
+	for _, d := range devs {
+		if len(serial) == 0 && d.usb.Path != path {
+			continue
+		}
+
+		s, err := d.status()
+		if err != nil {
+			continue
+		}
+
+		if len(serial) == 0 || s.Serial == serial {
+			return s, nil
+		}
+	}
+
+	if len(serial) == 0 {
+		return nil, fmt.Errorf("device %q not found", path)
+	}
+
+	return nil, fmt.Errorf("device %q not found", serial)
+}
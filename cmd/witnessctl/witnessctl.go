@@ -18,10 +18,18 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/transparency-dev/armored-witness-os/api"
 )
 
 const warning = `
@@ -41,6 +49,19 @@ The use of this tool is therefore **at your own risk**.
 ████████████████████████████████████████████████████████████████████████████████
 `
 
+const resetWarning = `
+████████████████████████████████████████████████████████████████████████████████
+
+                                **  WARNING  **
+
+A factory reset permanently erases all witness state, including its data,
+configuration and crash logs, the device reboots once erasure is complete.
+
+Installed firmware and rollback protection are left untouched.
+
+████████████████████████████████████████████████████████████████████████████████
+`
+
 var (
 	// knownSRKHashes maps known SRK hash values to the release environment they came from.
 	// These values MUST NOT be changed unless you really know what you're doing!
@@ -54,11 +75,31 @@ type Config struct {
 	devs []Device
 
 	hidPath string
+	json    bool
+
+	fleet         bool
+	fleetMutating bool
+	timeout       time.Duration
 
 	status      bool
 	consoleLogs bool
 	crashLogs   bool
 	hab         bool
+	reset       bool
+
+	recoverSlot        bool
+	recoverApplet      string
+	recoverProofBundle string
+	recoverPackage     string
+
+	install            string
+	installProofBundle string
+	installType        string
+
+	getCfg bool
+	// set records the flags given on the command line, so that only the
+	// configuration values changed by the operator are applied.
+	set map[string]bool
 
 	dhcp bool
 	ip   string
@@ -77,10 +118,23 @@ func init() {
 	conf = &Config{}
 
 	flag.StringVar(&conf.hidPath, "d", "", "HID path of witness device to act upon (use -s to list devices)")
+	flag.BoolVar(&conf.json, "json", false, "print results, and errors, as JSON")
+	flag.BoolVar(&conf.fleet, "fleet", false, "run the command on all devices concurrently, and print a summary")
+	flag.BoolVar(&conf.fleetMutating, "fleet_mutating", false, "allow commands which change the device state in fleet mode")
+	flag.DurationVar(&conf.timeout, "timeout", time.Minute, "per-device command timeout in fleet mode (0 for none), mutating commands are only timed out when set")
 	flag.BoolVar(&conf.status, "s", false, "get witness status")
 	flag.BoolVar(&conf.consoleLogs, "l", false, "get witness console/debug logs")
 	flag.BoolVar(&conf.crashLogs, "L", false, "get crash logs from most recent witness failure")
 	flag.BoolVar(&conf.hab, "H", false, "set HAB fuses")
+	flag.BoolVar(&conf.reset, "F", false, "factory reset (erases all witness state)")
+	flag.BoolVar(&conf.recoverSlot, "recover_slot", false, "boot the applet in the other slot (recovery mode only)")
+	flag.StringVar(&conf.recoverApplet, "recover_applet", "", "install the applet from this file (recovery mode only, requires -recover_proofbundle)")
+	flag.StringVar(&conf.recoverProofBundle, "recover_proofbundle", "", "proof bundle file for -recover_applet")
+	flag.StringVar(&conf.recoverPackage, "recover_package", "", "install the applet from this update package file (recovery mode only)")
+	flag.StringVar(&conf.install, "install", "", "install the firmware, or update package, from this file")
+	flag.StringVar(&conf.installProofBundle, "install_proofbundle", "", "proof bundle file for -install, unless installing an update package")
+	flag.StringVar(&conf.installType, "install_type", "applet", "firmware type for -install (applet or os), unless installing an update package")
+	flag.BoolVar(&conf.getCfg, "c", false, "get witness configuration")
 	flag.BoolVar(&conf.dhcp, "A", true, "enable DHCP")
 	flag.StringVar(&conf.ip, "a", "10.0.0.1", "set IP address")
 	flag.StringVar(&conf.mask, "m", "255.255.255.0", "set Netmask")
@@ -105,84 +159,419 @@ func (c *Config) detect() error {
 				return nil
 			}
 		}
-
+		return fmt.Errorf("device %q not found", c.hidPath)
 	}
 	c.devs = devs
 	return nil
 }
 
-func main() {
-	defer func() {
-		if flag.NFlag() == 0 {
-			flag.PrintDefaults()
+// command represents a witnessctl operation.
+type command struct {
+	name string
+	// mutating commands change the device state, they can only act on a
+	// single device which must be selected with -d when several are
+	// connected, unless allowed in fleet mode.
+	mutating bool
+	// run performs the command on a device, recording its output in r.
+	run func(d Device, r *result) error
+	// confirm, if set, asks the operator to confirm the command for a
+	// device before it is run.
+	confirm func(d Device) error
+}
+
+// command returns the operation selected by the command line flags, any
+// local inputs are read and validated upfront.
+func (c *Config) command() *command {
+	switch {
+	case c.hab:
+		return &command{"hab", true, habCmd, confirmHAB}
+	case c.reset:
+		return &command{"factory_reset", true, resetCmd, confirmReset}
+	case c.recoverSlot:
+		return &command{name: "recover_slot", mutating: true, run: func(d Device, r *result) error {
+			if err := d.switchAppletSlot(); err != nil {
+				return err
+			}
+			r.Message = "Applet slot switched, the device is rebooting"
+			return nil
+		}}
+	case len(c.recoverApplet) > 0:
+		if len(c.recoverProofBundle) == 0 {
+			fatal("recover_applet", exitUsage, "Please specify the applet proof bundle using -recover_proofbundle")
 		}
-	}()
+		elf, err := os.ReadFile(c.recoverApplet)
+		if err != nil {
+			fatal("recover_applet", exitUsage, "Failed to read applet: %v", err)
+		}
+		pb, err := os.ReadFile(c.recoverProofBundle)
+		if err != nil {
+			fatal("recover_applet", exitUsage, "Failed to read proof bundle: %v", err)
+		}
+		return &command{name: "recover_applet", mutating: true, run: func(d Device, r *result) error {
+			if err := d.recoveryInstall(elf, pb, nil); err != nil {
+				return err
+			}
+			r.Message = "Applet sent, the device reboots once it is installed (use -s to check for errors)"
+			return nil
+		}}
+	case len(c.recoverPackage) > 0:
+		buf, err := os.ReadFile(c.recoverPackage)
+		if err != nil {
+			fatal("recover_package", exitUsage, "Failed to read update package: %v", err)
+		}
+		p, err := api.ParseUpdatePackage(buf)
+		if err != nil {
+			fatal("recover_package", exitUsage, "%v", err)
+		}
+		if p.Type != api.FirmwareType_APPLET {
+			fatal("recover_package", exitUsage, "Update package contains %v firmware, only applets can be installed in recovery mode", p.Type)
+		}
+		pb, err := p.Proof.Bytes()
+		if err != nil {
+			fatal("recover_package", exitUsage, "%v", err)
+		}
+		return &command{name: "recover_package", mutating: true, run: func(d Device, r *result) error {
+			log.Printf("Installing applet %s from update package", p.Version)
+			if err := d.recoveryInstall(p.Firmware, pb, p.DeltaBase); err != nil {
+				return err
+			}
+			r.Message = "Applet sent, the device reboots once it is installed (use -s to check for errors)"
+			return nil
+		}}
+	case len(c.install) > 0:
+		return c.installCommand()
+	case c.status:
+		return &command{name: "status", run: func(d Device, r *result) error {
+			s, err := d.status()
+			if err != nil {
+				return err
+			}
+			r.Serial = s.Serial
+			r.Status, _ = protojson.Marshal(s)
+			r.text = s.Print()
+			return nil
+		}}
+	case c.consoleLogs:
+		return &command{name: "console_logs", run: func(d Device, r *result) (err error) {
+			r.Logs, err = d.consoleLogs()
+			r.text = r.Logs
+			return
+		}}
+	case c.crashLogs:
+		return &command{name: "crash_logs", run: func(d Device, r *result) (err error) {
+			r.Logs, err = d.crashLogs()
+			r.text = r.Logs
+			return
+		}}
+	case c.getCfg:
+		return &command{name: "get_config", run: func(d Device, r *result) error {
+			cfg, err := d.getCfg()
+			if err != nil {
+				return err
+			}
+			r.Config, _ = protojson.Marshal(cfg)
+			r.text = cfg.Print()
+			return nil
+		}}
+	case c.set["A"] || c.set["a"] || c.set["m"] || c.set["g"] || c.set["r"] || c.set["n"]:
+		if err := checkCfg(c.ip, c.mask, c.gw, c.dns); err != nil {
+			fatal("configure", exitUsage, "%v", err)
+		}
+		if c.fleet && c.set["a"] {
+			// The same static address can't be given to several devices.
+			fatal("configure", exitUsage, "Static addresses can't be configured in fleet mode, use -d")
+		}
+		return &command{name: "configure", mutating: true, run: c.configure}
+	}
 
-	flag.Parse()
+	return nil
+}
 
-	if err := conf.detect(); err != nil {
-		log.Fatalf("detect(): %v", err)
+// configure applies the configuration flags set by the operator on top of the
+// current device configuration.
+func (c *Config) configure(d Device, r *result) error {
+	cfg, err := d.getCfg()
+	if err != nil {
+		var re *rejectedError
+		if !errors.As(err, &re) {
+			// Devices running an OS which predates
+			// U2FHID_ARMORY_GET_CFG fail the command at the
+			// transport level, these are told apart from an
+			// unreachable device by a successful status request.
+			if _, serr := d.status(); serr != nil {
+				return fmt.Errorf("failed to get configuration: %w", err)
+			}
+		}
+		// Without a current configuration, e.g. before the applet
+		// has first run or on older OS versions, all flag values (or
+		// defaults) are used.
+		log.Printf("%s: WARNING: current configuration unavailable (%v), using flag values and defaults for all fields", d.usb.Path, err)
+		cfg = &api.Configuration{
+			DHCP:      c.dhcp,
+			IP:        c.ip,
+			Netmask:   c.mask,
+			Gateway:   c.gw,
+			Resolver:  c.dns,
+			NTPServer: c.ntp,
+		}
+	}
+
+	for f, v := range map[string]func(){
+		"A": func() { cfg.DHCP = c.dhcp },
+		"a": func() { cfg.IP = c.ip },
+		"m": func() { cfg.Netmask = c.mask },
+		"g": func() { cfg.Gateway = c.gw },
+		"r": func() { cfg.Resolver = c.dns },
+		"n": func() { cfg.NTPServer = c.ntp },
+	} {
+		if c.set[f] {
+			v()
+		}
 	}
 
+	if err := checkCfg(cfg.IP, cfg.Netmask, cfg.Gateway, cfg.Resolver); err != nil {
+		return err
+	}
+
+	if err := d.cfg(cfg); err != nil {
+		return err
+	}
+
+	r.Config, _ = protojson.Marshal(cfg)
+	r.text = cfg.Print()
+	r.Message = "Configuration updated"
+
+	return nil
+}
+
+// installCommand returns the command installing the firmware given with
+// -install, either as an update package or as a firmware image and proof
+// bundle.
+func (c *Config) installCommand() *command {
+	fw, err := os.ReadFile(c.install)
+	if err != nil {
+		fatal("install", exitUsage, "Failed to read firmware: %v", err)
+	}
+
+	var t api.FirmwareType
+	var pb, deltaBase []byte
+	var version string
+
 	switch {
-	case conf.hab:
-		s, err := conf.devs[0].status()
+	case bytes.HasPrefix(fw, []byte(api.UpdatePackageMagic)):
+		p, err := api.ParseUpdatePackage(fw)
 		if err != nil {
-			log.Printf("Failed to get status on %q: %c", conf.devs[0].usb.Path, err)
+			fatal("install", exitUsage, "%v", err)
 		}
-		log.Print(warning)
-		log.Print()
-
-		env, ok := knownSRKHashes[s.SRKHash]
+		if pb, err = p.Proof.Bytes(); err != nil {
+			fatal("install", exitUsage, "%v", err)
+		}
+		t, fw, deltaBase = p.Type, p.Firmware, p.DeltaBase
+		version = fmt.Sprintf("%v %s", t, p.Version)
+	case len(c.installProofBundle) == 0:
+		fatal("install", exitUsage, "Please specify the firmware proof bundle using -install_proofbundle")
+	default:
+		v, ok := api.FirmwareType_value[strings.ToUpper(c.installType)]
 		if !ok {
-			log.Printf("WARNING: SRK hash '%s' is UNKNOWN!", s.SRKHash)
-		} else {
-			log.Printf("Will fuse to %s release environment (SRK Hash: %s)", env, s.SRKHash)
+			fatal("install", exitUsage, "Unknown firmware type %q", c.installType)
+		}
+		t = api.FirmwareType(v)
+		version = fmt.Sprintf("%v firmware", t)
+		if pb, err = os.ReadFile(c.installProofBundle); err != nil {
+			fatal("install", exitUsage, "Failed to read proof bundle: %v", err)
 		}
+	}
 
-		if confirm("Proceed?") {
-			log.Print("Asking device to fuse itself...")
-			if err := conf.devs[0].hab(); err != nil {
-				log.Fatalf("%v", err)
-			}
-		} else {
-			if err := errors.New("User cancelled"); err != nil {
-				log.Fatalf("%v", err)
-			}
+	bundle, err := api.ParseProofBundle(pb)
+	if err != nil {
+		fatal("install", exitUsage, "Invalid proof bundle: %v", err)
+	}
+
+	release, err := bundle.Release()
+	if err != nil {
+		fatal("install", exitUsage, "Invalid proof bundle: %v", err)
+	}
+
+	return &command{name: "install", mutating: true, run: func(d Device, r *result) error {
+		log.Printf("%s: installing %s", d.usb.Path, version)
+		if err := d.install(t, fw, pb, deltaBase, release); err != nil {
+			return err
 		}
-	case conf.status:
-		for _, d := range conf.devs {
-			log.Printf("👁️‍🗨️ @ %s", d.usb.Path)
-			s, err := d.status()
-			if err != nil {
-				log.Printf("Failed to get status on %q: %v", d.usb.Path, err)
+		r.Message = fmt.Sprintf("Firmware installed, the device is running %v %v", t, release.Git.TagName)
+		return nil
+	}}
+}
+
+func confirmHAB(d Device) error {
+	s, err := d.status()
+	if err != nil {
+		return fmt.Errorf("failed to get status: %w", err)
+	}
+	log.Print(warning)
+	log.Print()
+
+	env, ok := knownSRKHashes[s.SRKHash]
+	if !ok {
+		log.Printf("WARNING: SRK hash '%s' is UNKNOWN!", s.SRKHash)
+	} else {
+		log.Printf("Will fuse to %s release environment (SRK Hash: %s)", env, s.SRKHash)
+	}
+
+	if !confirm(fmt.Sprintf("Proceed with device %q?", d.usb.Path)) {
+		return errCancelled
+	}
+	return nil
+}
+
+func habCmd(d Device, r *result) error {
+	log.Printf("Asking device %q to fuse itself...", d.usb.Path)
+	if err := d.hab(); err != nil {
+		return err
+	}
+	r.Message = "HAB fuses set"
+	return nil
+}
+
+func confirmReset(d Device) error {
+	log.Print(resetWarning)
+	log.Print()
+
+	if !confirm(fmt.Sprintf("Factory reset device %q?", d.usb.Path)) {
+		return errCancelled
+	}
+	return nil
+}
+
+func resetCmd(d Device, r *result) error {
+	log.Printf("Asking device %q to erase itself...", d.usb.Path)
+	if err := d.factoryReset(); err != nil {
+		return err
+	}
+	r.Message = "Factory reset in progress, the device will reboot once complete"
+	return nil
+}
+
+// run performs the command on the selected devices and reports the results,
+// it returns the exit code.
+func (c *Config) run(cmd *command) int {
+	switch {
+	case cmd.mutating && c.fleet && !c.fleetMutating:
+		fatal(cmd.name, exitUsage, "The %s command changes the device state, it requires -fleet_mutating in fleet mode", cmd.name)
+	case cmd.mutating && !c.fleet && len(c.devs) != 1:
+		fatal(cmd.name, exitUsage, "Please specify which device to act upon using -d")
+	}
+
+	rep := &report{Command: cmd.name}
+	confirmed := make([]bool, len(c.devs))
+
+	// Confirmations are interactive, they are therefore all asked upfront
+	// and one device at a time.
+	for i, d := range c.devs {
+		rep.Devices = append(rep.Devices, &result{Path: d.usb.Path})
+
+		if cmd.confirm != nil {
+			if err := cmd.confirm(d); err != nil {
+				rep.Devices[i].done(err)
+				continue
 			}
-			log.Printf("%s\n\n", s.Print())
 		}
-	case conf.consoleLogs:
-		for _, d := range conf.devs {
-			log.Printf("👁️‍🗨️ @ %s", d.usb.Path)
-			s, err := d.consoleLogs()
-			if err != nil {
-				log.Printf("Failed to get console logs on %q: %v", d.usb.Path, err)
+
+		confirmed[i] = true
+	}
+
+	if c.fleet {
+		c.runFleet(cmd, rep, confirmed)
+	}
+
+	for i, d := range c.devs {
+		if !c.fleet && confirmed[i] {
+			if !cmd.mutating && !c.json {
+				log.Printf("👁️‍🗨️ @ %s", d.usb.Path)
 			}
-			log.Printf("%s\n\n", s)
+			rep.Devices[i] = c.runDevice(cmd, d)
+		}
+
+		r := rep.Devices[i]
+
+		if r.ExitCode > rep.ExitCode {
+			rep.ExitCode = r.ExitCode
+		}
+
+		if c.json {
+			continue
 		}
-	case conf.crashLogs:
-		for _, d := range conf.devs {
+
+		if c.fleet && !cmd.mutating {
 			log.Printf("👁️‍🗨️ @ %s", d.usb.Path)
-			s, err := d.crashLogs()
-			if err != nil {
-				log.Printf("Failed to get crash logs on %q: %v", d.usb.Path, err)
-			}
-			log.Printf("%s\n\n", s)
 		}
-	case conf.dhcp || len(conf.ip) > 0 || len(conf.gw) > 0 || len(conf.dns) > 0 || len(conf.ntp) > 0:
-		if len(conf.devs) != 1 {
-			log.Fatal("Please specify which device to configure using -d")
+
+		switch {
+		case len(r.Error) > 0:
+			log.Printf("Failed to %s on %q: %s", strings.ReplaceAll(cmd.name, "_", " "), d.usb.Path, r.Error)
+		case len(r.text) > 0:
+			log.Printf("%s\n\n", r.text)
+		case len(r.Message) > 0:
+			log.Printf("%s: %s", d.usb.Path, r.Message)
+		}
+	}
+
+	switch {
+	case c.json:
+		rep.print()
+	case c.fleet:
+		printSummary(rep)
+	}
+
+	return rep.ExitCode
+}
+
+// runDevice performs the command on a device and returns its outcome.
+func (c *Config) runDevice(cmd *command, d Device) *result {
+	r := &result{Path: d.usb.Path}
+
+	if c.json || c.fleet {
+		// Identify the device, this is done before running the
+		// command as it may leave the device unavailable.
+		if s, err := d.status(); err == nil {
+			r.Serial = s.Serial
 		}
-		if err := conf.devs[0].cfg(conf.dhcp, conf.ip, conf.mask, conf.gw, conf.dns, conf.ntp); err != nil {
-			log.Fatalf("%v", err)
+	}
+
+	r.done(cmd.run(d, r))
+
+	return r
+}
+
+func main() {
+	defer func() {
+		if flag.NFlag() == 0 {
+			flag.PrintDefaults()
 		}
+	}()
+
+	flag.Parse()
+
+	conf.set = make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		conf.set[f.Name] = true
+	})
+
+	if conf.json {
+		// Keep stdout for the JSON report, with progress messages and
+		// prompts on stderr.
+		log.SetOutput(os.Stderr)
+	}
+
+	cmd := conf.command()
+	if cmd == nil {
+		return
+	}
+
+	if err := conf.detect(); err != nil {
+		fatal(cmd.name, exitNoDevice, "detect(): %v", err)
+	}
+
+	if code := conf.run(cmd); code != exitOK {
+		os.Exit(code)
 	}
 }
@@ -0,0 +1,63 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package compress implements the decompression of gzip compressed applet
+// images, shared by the OS and the host tools so that both accept the same
+// images.
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/transparency-dev/armored-witness-os/api"
+)
+
+// Magic identifies gzip compressed firmware images.
+var Magic = []byte{0x1f, 0x8b}
+
+// IsCompressed returns whether a firmware image is gzip compressed.
+func IsCompressed(buf []byte) bool {
+	return bytes.HasPrefix(buf, Magic)
+}
+
+// Decompress returns the decompressed firmware image, images which are not
+// compressed are returned unmodified.
+//
+// Decompressed images cannot exceed api.MaxFirmwareSize, the firmware slot
+// size, as manifests commit to the decompressed image.
+func Decompress(buf []byte) ([]byte, error) {
+	if !IsCompressed(buf) {
+		return buf, nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	elf, err := io.ReadAll(io.LimitReader(r, api.MaxFirmwareSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(elf) > api.MaxFirmwareSize {
+		return nil, fmt.Errorf("decompressed firmware image exceeds %d bytes", api.MaxFirmwareSize)
+	}
+
+	return elf, nil
+}
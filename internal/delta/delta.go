@@ -0,0 +1,260 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package delta implements binary deltas between firmware images, allowing
+// updates to be sent as the difference against the installed firmware.
+//
+// A delta consists of a header followed by a sequence of operations which
+// reconstruct the target image:
+//
+//	magic      8 bytes  "AWDELTA1"
+//	base       32 bytes SHA256 digest of the base image
+//	size       uvarint  length of the target image
+//	operations:
+//	  copy     0x00, uvarint offset, uvarint length (copy from base image)
+//	  add      0x01, uvarint length, data           (literal data)
+package delta
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Magic identifies binary deltas.
+const Magic = "AWDELTA1"
+
+const (
+	opCopy = 0x00
+	opAdd  = 0x01
+
+	// blockSize is the granularity at which base image data is matched.
+	blockSize = 64
+	// maxCandidates is the maximum number of base image offsets indexed
+	// for a block hash, bounding the matching effort on repetitive data
+	// (e.g. padding).
+	maxCandidates = 8
+	// prime is the rolling hash multiplier.
+	prime = 16777619
+)
+
+// IsDelta returns whether buf is a binary delta.
+func IsDelta(buf []byte) bool {
+	return bytes.HasPrefix(buf, []byte(Magic))
+}
+
+// Base returns the SHA256 digest of the base image a delta applies to.
+func Base(delta []byte) ([]byte, error) {
+	if !IsDelta(delta) || len(delta) < len(Magic)+sha256.Size {
+		return nil, errors.New("invalid delta header")
+	}
+
+	return delta[len(Magic) : len(Magic)+sha256.Size], nil
+}
+
+// Patch applies a delta to the base image returning the target image, which
+// cannot exceed limit bytes.
+func Patch(base []byte, delta []byte, limit int) ([]byte, error) {
+	digest, err := Base(delta)
+	if err != nil {
+		return nil, err
+	}
+
+	if h := sha256.Sum256(base); !bytes.Equal(h[:], digest) {
+		return nil, fmt.Errorf("base image mismatch (%x != %x)", h, digest)
+	}
+
+	r := bytes.NewReader(delta[len(Magic)+sha256.Size:])
+
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target size: %v", err)
+	}
+
+	if size > uint64(limit) {
+		return nil, fmt.Errorf("target image exceeds %d bytes", limit)
+	}
+
+	target := make([]byte, 0, size)
+
+	for r.Len() > 0 {
+		op, _ := r.ReadByte()
+
+		switch op {
+		case opCopy:
+			off, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, fmt.Errorf("invalid copy offset: %v", err)
+			}
+
+			n, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, fmt.Errorf("invalid copy length: %v", err)
+			}
+
+			if off > uint64(len(base)) || n > uint64(len(base))-off {
+				return nil, fmt.Errorf("copy exceeds base image (%d+%d > %d)", off, n, len(base))
+			}
+
+			if n > size-uint64(len(target)) {
+				return nil, errors.New("target image exceeds declared size")
+			}
+
+			target = append(target, base[off:off+n]...)
+		case opAdd:
+			n, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, fmt.Errorf("invalid add length: %v", err)
+			}
+
+			if n > uint64(r.Len()) {
+				return nil, errors.New("truncated add data")
+			}
+
+			if n > size-uint64(len(target)) {
+				return nil, errors.New("target image exceeds declared size")
+			}
+
+			data := make([]byte, n)
+			r.Read(data)
+			target = append(target, data...)
+		default:
+			return nil, fmt.Errorf("invalid operation 0x%x", op)
+		}
+	}
+
+	if uint64(len(target)) != size {
+		return nil, fmt.Errorf("target image size mismatch (%d != %d)", len(target), size)
+	}
+
+	return target, nil
+}
+
+// Diff returns a delta which transforms the base image into the target one.
+func Diff(base []byte, target []byte) []byte {
+	buf := &bytes.Buffer{}
+	w := bufio.NewWriter(buf)
+
+	h := sha256.Sum256(base)
+	w.WriteString(Magic)
+	w.Write(h[:])
+	writeUvarint(w, uint64(len(target)))
+
+	// index the base image at block boundaries
+	index := make(map[uint32][]int)
+	for off := 0; off+blockSize <= len(base); off += blockSize {
+		k := hash(base[off : off+blockSize])
+		if len(index[k]) < maxCandidates {
+			index[k] = append(index[k], off)
+		}
+	}
+
+	// pow is prime^(blockSize-1), used to roll the hash
+	pow := uint32(1)
+	for i := 0; i < blockSize-1; i++ {
+		pow *= prime
+	}
+
+	add := 0
+	i := 0
+
+	var k uint32
+	if len(target) >= blockSize {
+		k = hash(target[:blockSize])
+	}
+
+	for i+blockSize <= len(target) {
+		off, n := match(base, target, index[k], i)
+
+		if n == 0 {
+			if i+blockSize < len(target) {
+				k = (k-uint32(target[i])*pow)*prime + uint32(target[i+blockSize])
+			}
+			i++
+			continue
+		}
+
+		// extend the match backwards into pending literal data
+		for add < i && off > 0 && base[off-1] == target[i-1] {
+			off--
+			i--
+			n++
+		}
+
+		writeAdd(w, target[add:i])
+		writeCopy(w, off, n)
+
+		i += n
+		add = i
+
+		if i+blockSize <= len(target) {
+			k = hash(target[i : i+blockSize])
+		}
+	}
+
+	writeAdd(w, target[add:])
+	w.Flush()
+
+	return buf.Bytes()
+}
+
+// match returns the longest match, amongst candidate base image offsets, for
+// the target data at offset i.
+func match(base []byte, target []byte, candidates []int, i int) (off int, n int) {
+	for _, c := range candidates {
+		l := 0
+		for c+l < len(base) && i+l < len(target) && base[c+l] == target[i+l] {
+			l++
+		}
+
+		if l >= blockSize && l > n {
+			off = c
+			n = l
+		}
+	}
+
+	return
+}
+
+func hash(buf []byte) (k uint32) {
+	for _, b := range buf {
+		k = k*prime + uint32(b)
+	}
+	return
+}
+
+func writeUvarint(w io.Writer, v uint64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	w.Write(buf[:binary.PutUvarint(buf, v)])
+}
+
+func writeCopy(w *bufio.Writer, off int, n int) {
+	w.WriteByte(opCopy)
+	writeUvarint(w, uint64(off))
+	writeUvarint(w, uint64(n))
+}
+
+func writeAdd(w *bufio.Writer, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	w.WriteByte(opAdd)
+	writeUvarint(w, uint64(len(data)))
+	w.Write(data)
+}
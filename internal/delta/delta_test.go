@@ -0,0 +1,164 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delta
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math/rand"
+	"testing"
+)
+
+func random(seed int64, n int) []byte {
+	buf := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(buf)
+	return buf
+}
+
+func TestRoundTrip(t *testing.T) {
+	base := random(1, 64*1024)
+
+	// target with edits, insertions and deletions against base
+	target := append([]byte{}, base[:10000]...)
+	target = append(target, random(2, 3000)...)
+	target = append(target, base[12000:40000]...)
+	target = append(target, base[50000:]...)
+	target[20000] ^= 0xff
+
+	for _, test := range []struct {
+		name   string
+		base   []byte
+		target []byte
+	}{
+		{name: "edited", base: base, target: target},
+		{name: "identical", base: base, target: base},
+		{name: "unrelated", base: base, target: random(3, 1000)},
+		{name: "empty base", base: nil, target: target},
+		{name: "empty target", base: base, target: nil},
+		{name: "repetitive", base: make([]byte, 64*1024), target: make([]byte, 80*1024)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			d := Diff(test.base, test.target)
+
+			if !IsDelta(d) {
+				t.Fatalf("IsDelta() = false")
+			}
+
+			if got, err := Base(d); err != nil {
+				t.Fatalf("Base(): %v", err)
+			} else if want := sha256.Sum256(test.base); !bytes.Equal(got, want[:]) {
+				t.Errorf("Base() = %x, want %x", got, want)
+			}
+
+			got, err := Patch(test.base, d, len(test.target))
+			if err != nil {
+				t.Fatalf("Patch(): %v", err)
+			}
+
+			if !bytes.Equal(got, test.target) {
+				t.Errorf("Patch() returned a different target image")
+			}
+		})
+	}
+
+	if d := Diff(base, target); len(d) > len(target)/4 {
+		t.Errorf("delta of similar images is %d bytes, for a %d bytes target", len(d), len(target))
+	}
+}
+
+func TestPatchErrors(t *testing.T) {
+	base := random(1, 16*1024)
+	target := append(append([]byte{}, base[:8192]...), random(2, 100)...)
+	d := Diff(base, target)
+	header := len(Magic) + sha256.Size
+
+	corrupt := func(f func(d []byte) []byte) []byte {
+		return f(append([]byte{}, d...))
+	}
+
+	for _, test := range []struct {
+		name  string
+		base  []byte
+		delta []byte
+		limit int
+	}{
+		{
+			name:  "wrong base",
+			base:  random(3, 16*1024),
+			delta: d,
+			limit: len(target),
+		},
+		{
+			name:  "bad magic",
+			base:  base,
+			delta: corrupt(func(d []byte) []byte { d[0] ^= 0xff; return d }),
+			limit: len(target),
+		},
+		{
+			name:  "short header",
+			base:  base,
+			delta: d[:header-1],
+			limit: len(target),
+		},
+		{
+			name:  "missing size",
+			base:  base,
+			delta: d[:header],
+			limit: len(target),
+		},
+		{
+			name:  "truncated",
+			base:  base,
+			delta: d[:len(d)-1],
+			limit: len(target),
+		},
+		{
+			name:  "missing operations",
+			base:  base,
+			delta: d[:header+2],
+			limit: len(target),
+		},
+		{
+			name:  "invalid operation",
+			base:  base,
+			delta: append(append([]byte{}, d...), 0x02),
+			limit: len(target),
+		},
+		{
+			name:  "copy beyond base",
+			base:  base,
+			delta: append(append([]byte{}, d...), opCopy, 0xff, 0xff, 0x03, 0x01),
+			limit: len(target) + 1,
+		},
+		{
+			name:  "exceeds declared size",
+			base:  base,
+			delta: append(append([]byte{}, d...), opAdd, 0x01, 0x00),
+			limit: len(target) + 1,
+		},
+		{
+			name:  "exceeds limit",
+			base:  base,
+			delta: d,
+			limit: len(target) - 1,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Patch(test.base, test.delta, test.limit); err == nil {
+				t.Errorf("Patch() succeeded, want error")
+			}
+		})
+	}
+}
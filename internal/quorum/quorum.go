@@ -0,0 +1,79 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package quorum implements t-of-n signature thresholds on notes, shared by
+// the OS and the host tools so that both apply the same policy.
+package quorum
+
+import (
+	"fmt"
+	"strconv"
+
+	"golang.org/x/mod/sumdb/note"
+)
+
+// Parse parses the decimal representation of a threshold, returning def when
+// unset.
+func Parse(s string, def int) (int, error) {
+	if len(s) == 0 {
+		return def, nil
+	}
+
+	t, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid threshold %q: %v", s, err)
+	}
+
+	return t, nil
+}
+
+// Check returns an error unless t is a valid threshold for n keys, requiring
+// at least one signature.
+func Check(t int, n int) error {
+	if t < 1 || t > n {
+		return fmt.Errorf("threshold %d out of range for %d keys", t, n)
+	}
+
+	return nil
+}
+
+// Open verifies a note, which must carry valid signatures from at least t of
+// the given verifiers, and returns it along with the verifiers which signed
+// it.
+func Open(msg []byte, verifiers []note.Verifier, t int) (*note.Note, []note.Verifier, error) {
+	n, err := note.Open(msg, note.VerifierList(verifiers...))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if got, want := len(n.Sigs), t; got < want {
+		return nil, nil, fmt.Errorf("got %d verified signatures, want %d", got, want)
+	}
+
+	return n, Signers(verifiers, n.Sigs), nil
+}
+
+// Signers returns the verifiers which produced the given signatures.
+func Signers(verifiers []note.Verifier, sigs []note.Signature) (signing []note.Verifier) {
+	for _, v := range verifiers {
+		for _, sig := range sigs {
+			if v.Name() == sig.Name && v.KeyHash() == sig.Hash {
+				signing = append(signing, v)
+				break
+			}
+		}
+	}
+
+	return
+}
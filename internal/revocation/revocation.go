@@ -0,0 +1,155 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package revocation implements signed lists of revoked manifest signing
+// keys, and their storage record format.
+package revocation
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/sumdb/note"
+)
+
+// Header is the first line of revocation list notes.
+const Header = "armored-witness-revocations/v1"
+
+// List represents a signed list of revoked manifest signing keys.
+//
+// The list is a note, signed by the revocation key, with the following text:
+//
+//	armored-witness-revocations/v1
+//	<version>
+//	<key name>+<key hash>
+//	...
+//
+// where the version must increase with every new list and each revoked key
+// is identified by its note verifier name and hex encoded key hash (in either
+// case).
+type List struct {
+	// Version is the revocation list version.
+	Version uint64
+	// Revoked contains the revoked keys in <name>+<hash> format, with the
+	// hash in lowercase hex.
+	Revoked []string
+	// Digest is the SHA256 of the signed revocation list note.
+	Digest [sha256.Size]byte
+}
+
+// Parse verifies and parses a signed revocation list.
+func Parse(buf []byte, v note.Verifier) (*List, error) {
+	if v == nil {
+		return nil, errors.New("revocation lists are not supported")
+	}
+
+	n, err := note.Open(buf, note.VerifierList(v))
+	if err != nil {
+		return nil, fmt.Errorf("invalid revocation list: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(n.Text, "\n"), "\n")
+
+	if len(lines) < 2 || lines[0] != Header {
+		return nil, errors.New("invalid revocation list header")
+	}
+
+	l := &List{
+		Digest: sha256.Sum256(buf),
+	}
+
+	if l.Version, err = strconv.ParseUint(lines[1], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid revocation list version: %v", err)
+	}
+
+	for _, k := range lines[2:] {
+		name, hash, ok := strings.Cut(k, "+")
+		h, err := strconv.ParseUint(hash, 16, 32)
+		if !ok || len(name) == 0 || len(hash) != 8 || err != nil {
+			return nil, fmt.Errorf("invalid revoked key %q", k)
+		}
+
+		// normalize the key hash as matched by IsRevoked
+		l.Revoked = append(l.Revoked, key(name, uint32(h)))
+	}
+
+	return l, nil
+}
+
+// IsRevoked returns whether the key with the given note verifier name and key
+// hash has been revoked.
+func (l *List) IsRevoked(name string, hash uint32) bool {
+	k := key(name, hash)
+
+	for _, r := range l.Revoked {
+		if r == k {
+			return true
+		}
+	}
+
+	return false
+}
+
+// key returns the <name>+<hash> representation of a key, with its hash in
+// lowercase hex.
+func key(name string, hash uint32) string {
+	return fmt.Sprintf("%s+%08x", name, hash)
+}
+
+// Encode returns the storage record for a signed revocation list, which
+// prefixes it with its length.
+func Encode(buf []byte) []byte {
+	rec := make([]byte, 4, 4+len(buf))
+	binary.BigEndian.PutUint32(rec, uint32(len(buf)))
+
+	return append(rec, buf...)
+}
+
+// Decode verifies and parses the revocation list contained in a storage
+// record, an empty list is returned for never written (zeroed) records.
+//
+// The anchored version is the one of the last list known to have been
+// stored, until a list has been anchored (version zero) records which cannot
+// be parsed, such as erased (0xff) or uninitialized storage, are also
+// treated as empty.
+func Decode(rec []byte, v note.Verifier, anchored uint64) (*List, error) {
+	l, err := decode(rec, v)
+	if err != nil && anchored == 0 {
+		return &List{}, nil
+	}
+
+	return l, err
+}
+
+func decode(rec []byte, v note.Verifier) (*List, error) {
+	if len(rec) < 4 {
+		return nil, errors.New("invalid revocation list record")
+	}
+
+	size := binary.BigEndian.Uint32(rec)
+
+	switch {
+	case size == 0:
+		// erased, or never written, revocation list
+		return &List{}, nil
+	case int64(size) > int64(len(rec)-4):
+		return nil, errors.New("invalid revocation list length")
+	}
+
+	return Parse(rec[4:4+size], v)
+}
@@ -0,0 +1,186 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revocation
+
+import (
+	"bytes"
+	"crypto/rand"
+	"slices"
+	"testing"
+
+	"golang.org/x/mod/sumdb/note"
+)
+
+// recordSize is the length of the records read from storage.
+const recordSize = 16 * 1024
+
+func keys(t *testing.T) (note.Signer, note.Verifier) {
+	t.Helper()
+
+	skey, vkey, err := note.GenerateKey(rand.Reader, "revocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := note.NewSigner(skey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := note.NewVerifier(vkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, v
+}
+
+func record(buf []byte) []byte {
+	rec := make([]byte, recordSize)
+	copy(rec, buf)
+	return rec
+}
+
+func TestDecode(t *testing.T) {
+	s, v := keys(t)
+
+	list, err := note.Sign(&note.Note{Text: Header + "\n2\nexample+01234567\n"}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	garbage := make([]byte, recordSize)
+	rand.Read(garbage)
+	// ensure the length prefix is not zero
+	garbage[0] = 0x01
+
+	for _, test := range []struct {
+		name     string
+		rec      []byte
+		anchored uint64
+		version  uint64
+		wantErr  bool
+	}{
+		{name: "zeroed", rec: make([]byte, recordSize)},
+		{name: "zeroed, anchored", rec: make([]byte, recordSize), anchored: 2},
+		{name: "erased", rec: bytes.Repeat([]byte{0xff}, recordSize)},
+		{name: "erased, anchored", rec: bytes.Repeat([]byte{0xff}, recordSize), anchored: 2, wantErr: true},
+		{name: "garbage", rec: garbage},
+		{name: "garbage, anchored", rec: garbage, anchored: 2, wantErr: true},
+		{name: "short", rec: []byte{0xff}},
+		{name: "short, anchored", rec: []byte{0xff}, anchored: 2, wantErr: true},
+		{name: "list", rec: record(Encode(list)), version: 2},
+		{name: "list, anchored", rec: record(Encode(list)), anchored: 2, version: 2},
+		{name: "truncated list", rec: Encode(list)[:len(list)], anchored: 2, wantErr: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			l, err := Decode(test.rec, v, test.anchored)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("Decode() error = %v, want error %v", err, test.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if l.Version != test.version {
+				t.Errorf("Decode() version = %d, want %d", l.Version, test.version)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	s, v := keys(t)
+	other, _ := keys(t)
+
+	for _, test := range []struct {
+		name    string
+		text    string
+		signer  note.Signer
+		revoked []string
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			text:    Header + "\n1\nexample+01234567\nother+89abcdef\n",
+			signer:  s,
+			revoked: []string{"example+01234567", "other+89abcdef"},
+		},
+		{
+			name:    "uppercase",
+			text:    Header + "\n1\nexample+01234567\nother+89ABCDEF\n",
+			signer:  s,
+			revoked: []string{"example+01234567", "other+89abcdef"},
+		},
+		{
+			name:   "empty",
+			text:   Header + "\n1\n",
+			signer: s,
+		},
+		{
+			name:    "unknown signer",
+			text:    Header + "\n1\n",
+			signer:  other,
+			wantErr: true,
+		},
+		{
+			name:    "bad header",
+			text:    "revocations\n1\n",
+			signer:  s,
+			wantErr: true,
+		},
+		{
+			name:    "bad version",
+			text:    Header + "\none\n",
+			signer:  s,
+			wantErr: true,
+		},
+		{
+			name:    "bad key",
+			text:    Header + "\n1\nexample+0123\n",
+			signer:  s,
+			wantErr: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			buf, err := note.Sign(&note.Note{Text: test.text}, test.signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			l, err := Parse(buf, v)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("Parse() error = %v, want error %v", err, test.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if !slices.Equal(l.Revoked, test.revoked) {
+				t.Errorf("Parse() revoked = %v, want %v", l.Revoked, test.revoked)
+			}
+
+			if got, want := l.IsRevoked("example", 0x01234567), len(test.revoked) > 0; got != want {
+				t.Errorf("IsRevoked() = %v, want %v", got, want)
+			}
+
+			if got, want := l.IsRevoked("other", 0x89abcdef), len(test.revoked) > 0; got != want {
+				t.Errorf("IsRevoked(other) = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package witness implements verification of witness cosignatures on log
+// checkpoints, protecting against split-view attacks by the firmware
+// transparency log.
+package witness
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/sumdb/note"
+
+	"github.com/transparency-dev/armored-witness-os/internal/quorum"
+)
+
+const (
+	// algEd25519 identifies ed25519 note signatures.
+	algEd25519 = 0x01
+	// algCosignatureV1 identifies timestamped ed25519 witness cosignatures
+	// as defined by the C2SP tlog-cosignature specification.
+	algCosignatureV1 = 0x04
+)
+
+// Policy represents an N-of-M witness policy, where a checkpoint must carry
+// valid cosignatures from at least Threshold of the Verifiers.
+type Policy struct {
+	Verifiers []note.Verifier
+	Threshold int
+}
+
+// NewPolicy returns a witness policy from a list of witness verifier keys,
+// either in ed25519 note or cosignature/v1 format, and the number of them
+// required to cosign checkpoints.
+//
+// A policy with no verifiers, and a zero threshold, accepts any checkpoint.
+func NewPolicy(keys []string, threshold int) (*Policy, error) {
+	p := &Policy{
+		Threshold: threshold,
+	}
+
+	for _, k := range keys {
+		v, err := NewVerifier(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid witness verifier %q: %v", k, err)
+		}
+		p.Verifiers = append(p.Verifiers, v)
+	}
+
+	if threshold < 0 || threshold > len(p.Verifiers) {
+		return nil, fmt.Errorf("invalid threshold %d for %d witnesses", threshold, len(p.Verifiers))
+	}
+
+	return p, nil
+}
+
+// ParsePolicy returns a witness policy from its whitespace separated verifier
+// keys and decimal threshold string representations.
+func ParsePolicy(keys string, threshold string) (*Policy, error) {
+	t, err := quorum.Parse(threshold, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPolicy(strings.Fields(keys), t)
+}
+
+// Verify checks that the checkpoint note carries valid cosignatures from at
+// least the policy threshold of witnesses.
+func (p *Policy) Verify(checkpoint []byte) error {
+	if p.Threshold == 0 {
+		return nil
+	}
+
+	n, err := note.Open(checkpoint, note.VerifierList(p.Verifiers...))
+	if err != nil {
+		if _, ok := err.(*note.UnverifiedNoteError); ok {
+			return fmt.Errorf("got 0 witness cosignatures, want %d", p.Threshold)
+		}
+		return err
+	}
+
+	if got, want := len(n.Sigs), p.Threshold; got < want {
+		return fmt.Errorf("got %d witness cosignatures, want %d", got, want)
+	}
+
+	return nil
+}
+
+// NewVerifier returns a note verifier for a witness key, cosignature/v1 keys
+// are supported in addition to those supported by note.NewVerifier.
+func NewVerifier(vkey string) (note.Verifier, error) {
+	name, vkey, _ := strings.Cut(vkey, "+")
+	hash16, key64, _ := strings.Cut(vkey, "+")
+
+	key, err := base64.StdEncoding.DecodeString(key64)
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("malformed verifier key")
+	}
+
+	switch key[0] {
+	case algEd25519:
+		return note.NewVerifier(name + "+" + vkey)
+	case algCosignatureV1:
+		hash, err := strconv.ParseUint(hash16, 16, 32)
+		if err != nil || len(hash16) != 8 {
+			return nil, errors.New("malformed verifier key hash")
+		}
+
+		if len(key) != 1+ed25519.PublicKeySize {
+			return nil, errors.New("malformed verifier key")
+		}
+
+		if uint32(hash) != keyHash(name, key) {
+			return nil, errors.New("verifier key hash mismatch")
+		}
+
+		return &cosignatureV1{
+			name: name,
+			hash: uint32(hash),
+			key:  ed25519.PublicKey(key[1:]),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown verifier algorithm 0x%x", key[0])
+}
+
+func keyHash(name string, key []byte) uint32 {
+	h := sha256.New()
+	h.Write([]byte(name))
+	h.Write([]byte("\n"))
+	h.Write(key)
+	return binary.BigEndian.Uint32(h.Sum(nil))
+}
+
+// cosignatureV1 verifies cosignature/v1 signatures, which sign the note text
+// prefixed with a header containing the cosignature timestamp.
+type cosignatureV1 struct {
+	name string
+	hash uint32
+	key  ed25519.PublicKey
+}
+
+func (v *cosignatureV1) Name() string {
+	return v.name
+}
+
+func (v *cosignatureV1) KeyHash() uint32 {
+	return v.hash
+}
+
+func (v *cosignatureV1) Verify(msg []byte, sig []byte) bool {
+	if len(sig) != 8+ed25519.SignatureSize {
+		return false
+	}
+
+	t := binary.BigEndian.Uint64(sig[:8])
+	m := fmt.Sprintf("cosignature/v1\ntime %d\n%s", t, msg)
+
+	return ed25519.Verify(v.key, []byte(m), sig[8:])
+}
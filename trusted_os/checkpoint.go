@@ -0,0 +1,233 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"log"
+
+	"github.com/transparency-dev/armored-witness-os/api/rpc"
+	fmtlog "github.com/transparency-dev/formats/log"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+const (
+	// logStateLength is the length of a stored log state: the origin
+	// SHA256, the tree size and the tree hash.
+	logStateLength = sha256.Size + 8 + sha256.Size
+	// maxLogStates is the number of log states which fit the RPMB sector,
+	// and therefore the maximum number of accepted logs.
+	maxLogStates = 256 / logStateLength
+)
+
+// logState represents the largest verified checkpoint of a firmware log.
+type logState struct {
+	// Origin is the SHA256 of the log origin string.
+	Origin [sha256.Size]byte
+	// Size is the checkpoint tree size.
+	Size uint64
+	// Hash is the checkpoint tree hash.
+	Hash [sha256.Size]byte
+}
+
+// readLogStates returns the log states stored in the RPMB checkpoint sector.
+func readLogStates(r *RPMB) (states []logState, err error) {
+	buf := make([]byte, maxLogStates*logStateLength)
+
+	if err = r.transfer(checkpointSector, buf, nil, false); err != nil {
+		return
+	}
+
+	for i := 0; i < maxLogStates; i++ {
+		b := buf[i*logStateLength : (i+1)*logStateLength]
+
+		s := logState{}
+		copy(s.Origin[:], b)
+		s.Size = binary.BigEndian.Uint64(b[sha256.Size:])
+		copy(s.Hash[:], b[sha256.Size+8:])
+
+		// unused record
+		if s.Origin == [sha256.Size]byte{} {
+			continue
+		}
+
+		states = append(states, s)
+	}
+
+	return
+}
+
+// writeLogStates writes the log states to the RPMB checkpoint sector.
+func writeLogStates(r *RPMB, states []logState) error {
+	if len(states) > maxLogStates {
+		return fmt.Errorf("cannot store more than %d log states", maxLogStates)
+	}
+
+	buf := make([]byte, maxLogStates*logStateLength)
+
+	for i, s := range states {
+		b := buf[i*logStateLength : (i+1)*logStateLength]
+
+		copy(b, s.Origin[:])
+		binary.BigEndian.PutUint64(b[sha256.Size:], s.Size)
+		copy(b[sha256.Size+8:], s.Hash[:])
+	}
+
+	return r.transfer(checkpointSector, buf, nil, true)
+}
+
+// latestLogState returns the stored log state for the given log origin, or
+// nil if no checkpoint has been seen for it yet.
+func latestLogState(r *RPMB, origin string) (*logState, error) {
+	states, err := readLogStates(r)
+	if err != nil {
+		return nil, err
+	}
+
+	o := sha256.Sum256([]byte(origin))
+
+	for _, s := range states {
+		if s.Origin == o {
+			return &s, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// parseCheckpoint parses a checkpoint, of any accepted firmware log,
+// verifying its log signature.
+func parseCheckpoint(checkpoint []byte) (*fmtlog.Checkpoint, error) {
+	l, err := selectLog(checkpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	cp, _, _, err := fmtlog.ParseCheckpoint(checkpoint, l.Origin, l.Verifier)
+
+	return cp, err
+}
+
+// checkConsistency verifies that a checkpoint is consistent with the latest
+// checkpoint previously verified for the same log, rejecting checkpoints
+// which reveal a log fork.
+//
+// Like rollback protection, checkpoints are only tracked when RPMB is
+// available.
+//
+// Checkpoints of a different size than the latest one must be accompanied by
+// a consistency proof between the smaller and the larger of the two, allowing
+// bundles built against older checkpoints to be installed. The first
+// checkpoint seen for a log is trusted on first use.
+func checkConsistency(r *RPMB, checkpoint []byte, consistency [][]byte) error {
+	if !rollbackProtection() {
+		return nil
+	}
+
+	cp, err := parseCheckpoint(checkpoint)
+	if err != nil {
+		return reject(rpc.RejectedCheckpoint, "%v", err)
+	}
+
+	latest, err := latestLogState(r, cp.Origin)
+	if err != nil {
+		return fmt.Errorf("could not read latest checkpoint: %v", err)
+	}
+
+	switch {
+	case latest == nil:
+		return nil
+	case cp.Size < latest.Size:
+		if err := proof.VerifyConsistency(rfc6962.DefaultHasher, cp.Size, latest.Size, consistency, cp.Hash, latest.Hash[:]); err != nil {
+			return reject(rpc.RejectedConsistency, "log not consistent from size %d to %d: %v", cp.Size, latest.Size, err)
+		}
+	case cp.Size == latest.Size:
+		if !bytes.Equal(cp.Hash, latest.Hash[:]) {
+			return reject(rpc.RejectedConsistency, "log forked at size %d (%x != %x)", cp.Size, cp.Hash, latest.Hash)
+		}
+	default:
+		if err := proof.VerifyConsistency(rfc6962.DefaultHasher, latest.Size, cp.Size, consistency, latest.Hash[:], cp.Hash); err != nil {
+			return reject(rpc.RejectedConsistency, "log not consistent from size %d to %d: %v", latest.Size, cp.Size, err)
+		}
+	}
+
+	return nil
+}
+
+// updateLogState stores a checkpoint, which must have been previously
+// verified with checkConsistency, if larger than the latest one stored for
+// the same log.
+func updateLogState(r *RPMB, checkpoint []byte) error {
+	if !rollbackProtection() {
+		return nil
+	}
+
+	cp, err := parseCheckpoint(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	if len(cp.Hash) != sha256.Size {
+		return fmt.Errorf("invalid checkpoint hash length %d", len(cp.Hash))
+	}
+
+	states, err := readLogStates(r)
+	if err != nil {
+		return err
+	}
+
+	s := logState{
+		Origin: sha256.Sum256([]byte(cp.Origin)),
+		Size:   cp.Size,
+	}
+	copy(s.Hash[:], cp.Hash)
+
+	// Drop the states of logs which are no longer accepted, so that
+	// storage is not exhausted as logs are rotated.
+	accepted := states[:0]
+	for _, st := range states {
+		for _, l := range acceptedLogs {
+			if st.Origin == sha256.Sum256([]byte(l.Origin)) {
+				accepted = append(accepted, st)
+				break
+			}
+		}
+	}
+	states = accepted
+
+	i := 0
+	for ; i < len(states); i++ {
+		if states[i].Origin == s.Origin {
+			break
+		}
+	}
+
+	switch {
+	case i == len(states):
+		states = append(states, s)
+	case states[i].Size < s.Size:
+		states[i] = s
+	default:
+		return nil
+	}
+
+	log.Printf("SM storing latest checkpoint for %q (size %d)", cp.Origin, cp.Size)
+
+	return writeLogStates(r, states)
+}
@@ -0,0 +1,307 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/transparency-dev/armored-witness-boot/config"
+	"github.com/transparency-dev/armored-witness-os/api"
+	"github.com/transparency-dev/armored-witness-os/api/rpc"
+)
+
+// compatibility represents the compatibility declaration which firmware
+// manifests can carry, alongside the ftlog.FirmwareRelease fields, to require
+// a minimum version of the other firmware component, for example:
+//
+//	"compatibility": {"min_os_version": "0.3.0"}
+type compatibility struct {
+	// MinOSVersion is the oldest OS version which an applet release can
+	// run on.
+	MinOSVersion *semver.Version `json:"min_os_version,omitempty"`
+	// MinAppletVersion is the oldest applet version which an OS release
+	// can run.
+	MinAppletVersion *semver.Version `json:"min_applet_version,omitempty"`
+}
+
+// parseCompatibility returns the compatibility declaration contained in a
+// signed manifest note, signatures are not verified.
+func parseCompatibility(m []byte) (*compatibility, error) {
+	text, err := api.ManifestText(m)
+	if err != nil {
+		return nil, err
+	}
+
+	release := &struct {
+		Compatibility compatibility `json:"compatibility"`
+	}{}
+
+	if err := json.Unmarshal(text, release); err != nil {
+		return nil, fmt.Errorf("invalid compatibility declaration: %v", err)
+	}
+
+	return &release.Compatibility, nil
+}
+
+// checkCompatibility verifies that an OS and applet release are compatible
+// with each other, as declared in their respective manifests.
+func checkCompatibility(osManifest []byte, osVersion semver.Version, appletManifest []byte, appletVersion semver.Version) error {
+	if osManifest != nil {
+		c, err := parseCompatibility(osManifest)
+		if err != nil {
+			return err
+		}
+
+		if c.MinAppletVersion != nil && appletVersion.LessThan(*c.MinAppletVersion) {
+			return fmt.Errorf("OS %v requires applet %v or later, got %v", osVersion, c.MinAppletVersion, appletVersion)
+		}
+	}
+
+	if appletManifest != nil {
+		c, err := parseCompatibility(appletManifest)
+		if err != nil {
+			return err
+		}
+
+		if c.MinOSVersion != nil && osVersion.LessThan(*c.MinOSVersion) {
+			return fmt.Errorf("applet %v requires OS %v or later, got %v", appletVersion, c.MinOSVersion, osVersion)
+		}
+	}
+
+	return nil
+}
+
+// checkRunningCompatibility verifies that a firmware update, of the given
+// type, is compatible with the running firmware of the other type.
+//
+// The manifest, and its version, must have already been verified (see
+// verifyFirmware) as its compatibility declaration is trusted.
+func checkRunningCompatibility(t FirmwareType, manifest []byte, version semver.Version) error {
+	switch t {
+	case Firmware_Applet:
+		return checkCompatibility(nil, osVersion, manifest, version)
+	case Firmware_OS:
+		// Without a loaded applet there is nothing to be compatible with.
+		if (loadedAppletVersion == semver.Version{}) {
+			return nil
+		}
+		return checkCompatibility(manifest, version, nil, loadedAppletVersion)
+	}
+
+	return fmt.Errorf("unknown firmware type %v", t)
+}
+
+// checkCombined returns an error if a combined update is in progress, either
+// receiving or staging its firmware images or activating them, as individual
+// updates would interfere with it.
+//
+// It must be invoked with otaLock held.
+func checkCombined(storage Card, b *rpc.FirmwareUpdate) error {
+	if combinedStaged.open {
+		return errors.New("combined update in progress")
+	}
+
+	// The journal is only checked when an upload starts, to avoid reading
+	// it for every chunk.
+	if b.Sequence != 0 || storage == nil {
+		return nil
+	}
+
+	if j, err := readJournal(storage); err != nil {
+		return fmt.Errorf("could not read combined update journal: %v", err)
+	} else if j != nil {
+		return errors.New("combined update activation in progress")
+	}
+
+	return nil
+}
+
+// journal records the configs being activated by a combined update, allowing
+// an interrupted activation to be completed on the next boot.
+type journal struct {
+	OS     []byte
+	Applet []byte
+	// Digest is the SHA256 of the OS and applet configs, used to detect
+	// incomplete journal writes.
+	Digest []byte
+}
+
+func (j *journal) digest() []byte {
+	h := sha256.New()
+	h.Write(j.OS)
+	h.Write(j.Applet)
+	return h.Sum(nil)
+}
+
+// readJournal returns the combined update journal, or nil if no combined
+// activation is in progress.
+func readJournal(storage Card) (*journal, error) {
+	buf, err := storage.Read(journalBlock*expectedBlockSize, journalNumBlocks*expectedBlockSize)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &journal{}
+	if err := gob.NewDecoder(bytes.NewBuffer(buf)).Decode(j); err != nil {
+		// erased, or never written, journal block
+		return nil, nil
+	}
+
+	if !bytes.Equal(j.digest(), j.Digest) {
+		log.Printf("SM ignoring incomplete combined update journal")
+		return nil, nil
+	}
+
+	return j, nil
+}
+
+// applyJournal writes the configs recorded in the journal to the blocks read
+// at boot, the OS config is written last as it is the one read by the
+// bootloader, and then erases the journal.
+func applyJournal(storage Card, j *journal) error {
+	for _, c := range []struct {
+		t   FirmwareType
+		buf []byte
+	}{
+		{Firmware_Applet, j.Applet},
+		{Firmware_OS, j.OS},
+	} {
+		conf := &config.Config{}
+		if err := conf.Decode(c.buf); err != nil {
+			return fmt.Errorf("invalid journaled %s config: %v", c.t, err)
+		}
+
+		if err := writeConfig(storage, c.t, conf); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("SM erasing combined update journal @ 0x%x", journalBlock)
+	return erase(storage, journalBlock, journalNumBlocks)
+}
+
+// activateCombined re-verifies the staged OS and applet updates, and their
+// compatibility, and writes both configs to the blocks read at boot.
+//
+// The configs are first recorded in a journal so that, if activation is
+// interrupted, completeCombined() can finish it on the next boot and the OS
+// and applet are never left mismatched.
+func activateCombined(storage Card, rpmb *RPMB) error {
+	j := &journal{}
+	versions := make(map[FirmwareType]semver.Version)
+	confs := make(map[FirmwareType]*config.Config)
+
+	for _, t := range []FirmwareType{Firmware_OS, Firmware_Applet} {
+		conf, err := readStaged(storage, t)
+		if err != nil {
+			return err
+		}
+		if conf == nil {
+			return fmt.Errorf("no %s update staged", t)
+		}
+
+		if err := verifyStaged(storage, rpmb, t, conf); err != nil {
+			return err
+		}
+
+		manifest, err := api.ParseManifest(conf.Bundle.Manifest)
+		if err != nil {
+			return err
+		}
+
+		versions[t] = manifest.Git.TagName
+		confs[t] = conf
+	}
+
+	if err := checkCompatibility(
+		confs[Firmware_OS].Bundle.Manifest, versions[Firmware_OS],
+		confs[Firmware_Applet].Bundle.Manifest, versions[Firmware_Applet]); err != nil {
+		return err
+	}
+
+	var err error
+
+	if j.OS, err = confs[Firmware_OS].Encode(); err != nil {
+		return err
+	}
+	if j.Applet, err = confs[Firmware_Applet].Encode(); err != nil {
+		return err
+	}
+	j.Digest = j.digest()
+
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(j); err != nil {
+		return err
+	}
+
+	if buf.Len() > journalNumBlocks*expectedBlockSize {
+		return errors.New("combined update journal too large")
+	}
+
+	log.Printf("SM flashing combined update journal (%d bytes) @ 0x%x", buf.Len(), journalBlock)
+	if err := flash(storage, buf.Bytes(), journalBlock); err != nil {
+		return fmt.Errorf("journal flashing error: %v", err)
+	}
+
+	if err := applyJournal(storage, j); err != nil {
+		return err
+	}
+
+	for _, t := range []FirmwareType{Firmware_OS, Firmware_Applet} {
+		if err = discardStaged(storage, t); err != nil {
+			log.Printf("SM failed to erase staged %s config: %v", t, err)
+		}
+	}
+
+	log.Printf("SM combined update complete")
+	return nil
+}
+
+// completeCombined finishes any combined activation interrupted by a reset,
+// it must be invoked at boot before the applet is loaded.
+//
+// It returns true if the running OS is not the one activated, in which case
+// a reboot is required.
+func completeCombined(storage Card) (bool, error) {
+	if storage == nil {
+		return false, nil
+	}
+
+	j, err := readJournal(storage)
+	if err != nil || j == nil {
+		return false, err
+	}
+
+	log.Printf("SM completing interrupted combined update")
+
+	conf := &config.Config{}
+	if err := conf.Decode(j.OS); err != nil {
+		return false, fmt.Errorf("invalid journaled OS config: %v", err)
+	}
+
+	if err := applyJournal(storage, j); err != nil {
+		return false, err
+	}
+
+	return conf.Offset != osLoadedFromBlock*expectedBlockSize, nil
+}
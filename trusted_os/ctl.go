@@ -58,8 +58,6 @@ type controlInterface struct {
 	// SRKHash, if set, is the hex encoded SHA256 which may be fused into the device to enable HAB.
 	SRKHash string
 
-	ota *otaBuffer
-
 	logBuffer []byte
 }
 
@@ -70,8 +68,11 @@ func getStatus() (s *api.Status) {
 		Build:    Build,
 		Version:  osVersion.String(),
 		Runtime:  fmt.Sprintf("%s %s/%s", runtime.Version(), runtime.GOOS, runtime.GOARCH),
+
+		AppletSigners: loadedAppletSigners,
 		// TODO(jayhou): set IdentityCounter here.
 	}
+	s.Recovery, s.RecoveryReason = inRecovery()
 	if witnessStatus != nil {
 		s.Witness = &api.WitnessStatus{
 			Identity:          witnessStatus.Identity,
@@ -105,7 +106,14 @@ func (ctl *controlInterface) HandleMessage(_ []byte) (_ []byte) {
 }
 
 func (ctl *controlInterface) Status(_ []byte) (res []byte) {
-	res, _ = proto.Marshal(getStatus())
+	s := getStatus()
+
+	// the execution context is only set once the applet is loaded
+	if ctl.RPC.Ctx != nil {
+		s.AppletVersion = loadedAppletVersion.String()
+	}
+
+	res, _ = proto.Marshal(s)
 	return
 }
 
@@ -117,15 +125,15 @@ func (ctl *controlInterface) Config(req []byte) (res []byte) {
 	ctl.RPC.Cfg = req
 
 	if ctl.RPC.Ctx != nil {
-		var err error
-
+		// The applet is not reloaded here: the execution loop in
+		// main() re-verifies and restarts it, with the new
+		// configuration, once it has exited. Loading it here as well
+		// would run a second instance concurrently, from taELF which
+		// is only set for embedded applets and is neither
+		// decompressed nor verified.
 		log.Printf("SM received configuration update, restarting applet")
 		ctl.RPC.Ctx.Stop()
 		<-ctl.RPC.Ctx.Done()
-
-		if _, err = loadApplet(taELF, ctl); err != nil {
-			return api.ErrorResponse(err)
-		}
 	} else {
 		log.Printf("SM received configuration update w/o applet running")
 	}
@@ -133,6 +141,25 @@ func (ctl *controlInterface) Config(req []byte) (res []byte) {
 	return api.EmptyResponse()
 }
 
+// GetConfig returns the current Trusted Applet configuration, as an
+// api.Configuration payload.
+func (ctl *controlInterface) GetConfig(_ []byte) []byte {
+	if len(ctl.RPC.Cfg) == 0 {
+		return api.ErrorResponse(errors.New("no configuration available"))
+	}
+
+	cfg := &api.Configuration{}
+	if err := proto.Unmarshal(ctl.RPC.Cfg, cfg); err != nil {
+		return api.ErrorResponse(fmt.Errorf("invalid configuration: %v", err))
+	}
+
+	res := &api.Response{
+		Payload: cfg.Bytes(),
+	}
+
+	return res.Bytes()
+}
+
 func (ctl *controlInterface) HAB(_ []byte) []byte {
 	srkh, err := hex.DecodeString(ctl.SRKHash)
 	if err != nil {
@@ -158,6 +185,215 @@ func (ctl *controlInterface) HAB(_ []byte) []byte {
 	return api.EmptyResponse()
 }
 
+// FactoryReset erases all witness state, see factoryReset(). The request is
+// acknowledged immediately as erasure takes longer than the HID response
+// timeout, the Trusted Applet is halted and the device rebooted once done.
+func (ctl *controlInterface) FactoryReset(_ []byte) []byte {
+	if ctl.RPC.Storage == nil {
+		return api.ErrorResponse(errors.New("missing Storage"))
+	}
+
+	log.Printf("SM received factory reset request, halting applet")
+
+	go func() {
+		haltApplet(ctl.RPC)
+
+		if err := factoryReset(ctl.RPC); err != nil {
+			log.Printf("SM factory reset error, %v", err)
+		}
+
+		ctl.RPC.Reboot(nil, nil)
+	}()
+
+	return api.EmptyResponse()
+}
+
+// SwitchAppletSlot boots the applet installed in the previously active slot,
+// see switchAppletSlot(), the device is rebooted if successful.
+//
+// It is only available in recovery mode.
+func (ctl *controlInterface) SwitchAppletSlot(_ []byte) []byte {
+	if ok, _ := inRecovery(); !ok {
+		return api.ErrorResponse(errors.New("not in recovery mode"))
+	}
+
+	if err := switchAppletSlot(ctl.RPC.Storage, ctl.RPC.RPMB); err != nil {
+		return api.ErrorResponse(err)
+	}
+
+	go ctl.RPC.Reboot(nil, nil)
+
+	return api.EmptyResponse()
+}
+
+// otaInstall tracks the installation of firmware received through the
+// control interface, which completes after the last chunk is acknowledged.
+var otaInstall struct {
+	sync.Mutex
+
+	// installing is set while an installation is in progress.
+	installing bool
+	// err is the outcome of the last installation, if failed.
+	err error
+}
+
+// OTA receives a firmware update chunk, for the firmware type given in the
+// request, as described for RPC.InstallOS. The response payload is the
+// api.FirmwareUpdateStatus expected for the next chunk.
+//
+// The message carrying the proof bundle completes the upload, it is
+// acknowledged immediately as verification and flashing take longer than the
+// HID response timeout, the installation can then be followed with
+// OTAStatus. The device is rebooted once the update is installed.
+//
+// The upload buffers are shared with the applet RPCs, chunks are refused
+// while the applet has an upload of the same firmware type in progress.
+func (ctl *controlInterface) OTA(req []byte) []byte {
+	m := &api.FirmwareUpdate{}
+	if err := proto.Unmarshal(req, m); err != nil {
+		return api.ErrorResponse(err)
+	}
+
+	return ctl.ota(m)
+}
+
+// RecoveryInstall receives an applet update chunk, as described for OTA.
+//
+// It is only available in recovery mode, where an installation failure is
+// reported in the recovery reason of the status.
+func (ctl *controlInterface) RecoveryInstall(req []byte) []byte {
+	if ok, _ := inRecovery(); !ok {
+		return api.ErrorResponse(errors.New("not in recovery mode"))
+	}
+
+	m := &api.FirmwareUpdate{}
+	if err := proto.Unmarshal(req, m); err != nil {
+		return api.ErrorResponse(err)
+	}
+	m.Type = api.FirmwareType_APPLET
+
+	return ctl.ota(m)
+}
+
+// OTAStatus returns, as api.FirmwareUpdateStatus payload, the progress of the
+// update sent with OTA for the firmware type given in the request.
+func (ctl *controlInterface) OTAStatus(req []byte) []byte {
+	m := &api.FirmwareUpdate{}
+	if err := proto.Unmarshal(req, m); err != nil {
+		return api.ErrorResponse(err)
+	}
+
+	status := &rpc.FirmwareUpdateStatus{}
+	if err := installStatus(otaControl, FirmwareType(m.Type), status); err != nil {
+		return api.ErrorResponse(err)
+	}
+
+	otaInstall.Lock()
+	defer otaInstall.Unlock()
+
+	s := &api.FirmwareUpdateStatus{
+		Sequence:   uint32(status.Sequence),
+		Offset:     status.Offset,
+		Installing: otaInstall.installing,
+	}
+
+	if otaInstall.err != nil {
+		s.Error = otaInstall.err.Error()
+	}
+
+	return otaStatusResponse(s)
+}
+
+// ota handles a firmware update chunk received through the control
+// interface.
+func (ctl *controlInterface) ota(m *api.FirmwareUpdate) []byte {
+	t := FirmwareType(m.Type)
+
+	if _, err := uploadFor(t); err != nil {
+		return api.ErrorResponse(err)
+	}
+
+	otaInstall.Lock()
+	defer otaInstall.Unlock()
+
+	if otaInstall.installing {
+		return api.ErrorResponse(errors.New("installation in progress"))
+	}
+
+	u := &rpc.FirmwareUpdate{
+		Sequence:  uint(m.Sequence),
+		Offset:    m.Offset,
+		Digest:    m.Digest,
+		Image:     m.Image,
+		DeltaBase: m.DeltaBase,
+	}
+
+	if len(m.ProofBundle) > 0 {
+		pb, err := api.ParseProofBundle(m.ProofBundle)
+		if err != nil {
+			return api.ErrorResponse(err)
+		}
+		u.Proof = pb.Config()
+
+		otaInstall.installing = true
+		otaInstall.err = nil
+
+		go ctl.install(t, u)
+
+		return api.EmptyResponse()
+	}
+
+	status := &rpc.FirmwareUpdateStatus{}
+	if _, err := ctl.RPC.stage(otaControl, t, u, status, false); err != nil {
+		return api.ErrorResponse(err)
+	}
+
+	return otaStatusResponse(&api.FirmwareUpdateStatus{
+		Sequence: uint32(status.Sequence),
+		Offset:   status.Offset,
+	})
+}
+
+// install verifies, stages and activates the last firmware update chunk.
+func (ctl *controlInterface) install(t FirmwareType, u *rpc.FirmwareUpdate) {
+	log.Printf("SM installing %s update from control interface", t)
+
+	err := func() error {
+		if _, err := ctl.RPC.stage(otaControl, t, u, &rpc.FirmwareUpdateStatus{}, false); err != nil {
+			return fmt.Errorf("%s install failed, %v", t, err)
+		}
+
+		if err := ctl.RPC.ActivateStaged(t, nil); err != nil {
+			return fmt.Errorf("%s activation failed, %v", t, err)
+		}
+
+		return nil
+	}()
+
+	otaInstall.Lock()
+	defer otaInstall.Unlock()
+
+	otaInstall.installing = false
+	otaInstall.err = err
+
+	if err == nil {
+		return
+	}
+
+	if ok, _ := inRecovery(); ok {
+		enterRecovery(err.Error())
+	} else {
+		log.Printf("SM %v", err)
+	}
+}
+
+func otaStatusResponse(s *api.FirmwareUpdateStatus) []byte {
+	res := &api.Response{}
+	res.Payload, _ = proto.Marshal(s)
+
+	return res.Bytes()
+}
+
 func (ctl *controlInterface) handleLogsRequest(r []byte, l func() []byte) (res []byte) {
 	req := &api.LogMessagesRequest{}
 	if err := proto.Unmarshal(r, req); err != nil {
@@ -16,6 +16,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"log"
 	"runtime"
@@ -26,26 +27,38 @@ import (
 
 	"github.com/transparency-dev/armored-witness-boot/config"
 	"github.com/transparency-dev/armored-witness-common/release/firmware"
+	"github.com/transparency-dev/armored-witness-os/api"
+	"github.com/transparency-dev/armored-witness-os/api/rpc"
+	"github.com/transparency-dev/armored-witness-os/internal/compress"
 )
 
 // imx6_usdhc: 15 GB/14 GiB card detected {MMC:true SD:false HC:true HS:true DDR:false Rate:150 BlockSize:512 Blocks:30576640
 const (
-	expectedBlockSize = 512 // Expected size of MMC block in bytes
-	otaLimit          = 31457280
-	taConfBlock       = 0x200000
-	taBlockA          = 0x200050
-	taBlockB          = 0x2FD050
-	osConfBlock       = 0x5000
-	osBlockA          = 0x5050
-	osBlockB          = 0x102828
-	crashLogBlock     = 0x1D20000 // For storing contents of log ringbuffer on applet crash for later investigation.
-	crashLogNumBlocks = 0x800     // 1MB
-	batchSize         = 2048
+	expectedBlockSize   = 512 // Expected size of MMC block in bytes
+	otaLimit            = api.MaxFirmwareSize
+	taConfBlock         = 0x200000
+	taBlockA            = 0x200050
+	taBlockB            = 0x2FD050
+	taSlotNumBlocks     = taBlockB - taBlockA
+	osConfBlock         = 0x5000
+	osBlockA            = 0x5050
+	osBlockB            = 0x102828
+	osSlotNumBlocks     = osBlockB - osBlockA
+	osStagedConfBlock   = 0x3FC000  // Config for a verified OS update in the inactive slot, pending activation.
+	taStagedConfBlock   = 0x3FC050  // Config for a verified applet update in the inactive slot, pending activation.
+	journalBlock        = 0x3FC0A0  // Record of an in-progress combined OS and applet activation.
+	journalNumBlocks    = 0x100     // 128KB
+	revocationBlock     = 0x3FC1A0  // Signed manifest signer revocation list.
+	revocationNumBlocks = 0x20      // 16KB
+	taFallbackConfBlock = 0x3FC1C0  // Config for the previously active applet slot, used in recovery mode.
+	crashLogBlock       = 0x1D20000 // For storing contents of log ringbuffer on applet crash for later investigation.
+	crashLogNumBlocks   = 0x800     // 1MB
+	batchSize           = 2048
 )
 
 const (
-	Firmware_Applet FirmwareType = iota
-	Firmware_OS
+	Firmware_Applet = rpc.FirmwareApplet
+	Firmware_OS     = rpc.FirmwareOS
 )
 
 var (
@@ -59,25 +72,7 @@ var (
 )
 
 // FirmwareType represents the types of updatable firmware.
-type FirmwareType int
-
-func (ft FirmwareType) String() string {
-	switch ft {
-	case Firmware_Applet:
-		return "applet"
-	case Firmware_OS:
-		return "OS"
-	}
-	panic(fmt.Errorf("Unknown FirmwareType %v", ft))
-}
-
-type otaBuffer struct {
-	total  uint32
-	seq    uint32
-	sig    []byte
-	buf    []byte
-	bundle *config.ProofBundle
-}
+type FirmwareType = rpc.FirmwareType
 
 // Card mostly mirrors the public API of the usdhc.Card struct, allowing
 // substitutions for testing.
@@ -135,6 +130,8 @@ func determineLoadedOSBlock(card Card) error {
 // read reads the trusted applet bundle from internal storage, the
 // applet and FT proofs are *not* verified by this function.
 //
+// Compressed applet images are returned decompressed.
+//
 // This function will update appletLoadedFromBlock with the MMC block index
 // the applet firmware image was loaded from.
 func read(card Card) (fw *firmware.Bundle, err error) {
@@ -155,11 +152,15 @@ func read(card Card) (fw *firmware.Bundle, err error) {
 		Manifest:       conf.Bundle.Manifest,
 	}
 
-	fw.Firmware, err = card.Read(conf.Offset, conf.Size)
+	buf, err := card.Read(conf.Offset, conf.Size)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read firmware: %v", err)
 	}
 
+	if fw.Firmware, err = compress.Decompress(buf); err != nil {
+		return nil, fmt.Errorf("failed to decompress firmware: %v", err)
+	}
+
 	appletLoadedFromBlock = conf.Offset / expectedBlockSize
 	switch appletLoadedFromBlock {
 	case taBlockA:
@@ -211,6 +212,33 @@ func flash(card Card, buf []byte, lba int) (err error) {
 	return
 }
 
+// erase overwrites numBlocks of internal storage, starting at lba, with zeros.
+func erase(card Card, lba int, numBlocks int) (err error) {
+	blockSize := card.Info().BlockSize
+	if blockSize != expectedBlockSize {
+		return fmt.Errorf("h/w invariant error - expected MMC blocksize %d, found %d", expectedBlockSize, blockSize)
+	}
+
+	// write in chunks to limit DMA requirements
+	zero := make([]byte, blockSize*batchSize)
+	for blocks := 0; blocks < numBlocks; {
+		chunk := zero
+		if r := numBlocks - blocks; r < batchSize {
+			chunk = zero[:r*blockSize]
+		}
+		if err = card.WriteBlocks(lba+blocks, chunk); err != nil {
+			return
+		}
+		blocks += len(chunk) / blockSize
+
+		if blocks%(batchSize*64) == 0 {
+			log.Printf("erased %d/%d blocks", blocks, numBlocks)
+		}
+	}
+
+	return
+}
+
 func blinkenLights() (func(), func()) {
 	var exit = make(chan bool)
 	cancel := func() { close(exit) }
@@ -237,81 +265,134 @@ func blinkenLights() (func(), func()) {
 	return blink, cancel
 }
 
-// updateApplet verifies an applet update and flashes it to internal storage
-func updateApplet(storage Card, taELF []byte, pb config.ProofBundle) (err error) {
+// update verifies a firmware update, and the consistency of its checkpoint
+// with the latest one verified, and stages it to internal storage.
+//
+// When checkRunning is set the update must also be compatible with the
+// running firmware of the other type.
+func update(storage Card, rpmb *RPMB, t FirmwareType, elf []byte, pb config.ProofBundle, consistency [][]byte, checkRunning bool) error {
 	// First, verify everything is correct and that, as far as we can tell,
-	// we would succeed in loadering and launching this applet upon next boot.
-	bundle := firmware.Bundle{
-		Checkpoint:     pb.Checkpoint,
-		Index:          pb.LogIndex,
-		InclusionProof: pb.InclusionProof,
-		Manifest:       pb.Manifest,
-		Firmware:       taELF,
-	}
-	if _, err := AppletBundleVerifier.Verify(bundle); err != nil {
+	// we would succeed in loadering and launching this firmware upon next boot.
+	manifest, err := verifyFirmware(rpmb, t, newBundle(elf, pb))
+	if err != nil {
 		return err
 	}
-	log.Printf("SM verified applet bundle for update")
 
-	return flashFirmware(storage, Firmware_Applet, taELF, pb)
-}
+	if checkRunning {
+		if err := checkRunningCompatibility(t, pb.Manifest, manifest.Git.TagName); err != nil {
+			return err
+		}
+	}
 
-// updateOS verifies an OS update and flashes it to internal storage
-func updateOS(storage Card, osELF []byte, pb config.ProofBundle) (err error) {
-	// First, verify everything is correct and that, as far as we can tell,
-	// we would succeed in loadering and launching this applet upon next boot.
-	bundle := firmware.Bundle{
-		Checkpoint:     pb.Checkpoint,
-		Index:          pb.LogIndex,
-		InclusionProof: pb.InclusionProof,
-		Manifest:       pb.Manifest,
-		Firmware:       osELF,
-	}
-	if _, err := OSBundleVerifier.Verify(bundle); err != nil {
+	if err := checkConsistency(rpmb, pb.Checkpoint, consistency); err != nil {
 		return err
 	}
-	log.Printf("SM verified applet bundle for update")
+	log.Printf("SM verified %s bundle for update", t)
 
-	return flashFirmware(storage, Firmware_OS, osELF, pb)
-}
-
-// flashFirmware writes config & elf bytes to the MMC in the correct region for the specificed type of firmware.
-func flashFirmware(storage Card, t FirmwareType, elf []byte, pb config.ProofBundle) error {
-	if storage == nil {
-		return fmt.Errorf("Flashing %s error: missing Storage", t)
+	// The checkpoint is now known to be valid, record it regardless of
+	// the update outcome. Failing to do so would leave later checkpoints
+	// unchecked, the update is therefore aborted.
+	if err := updateLogState(rpmb, pb.Checkpoint); err != nil {
+		return fmt.Errorf("failed to store latest checkpoint: %v", err)
 	}
 
-	blink, cancel := blinkenLights()
-	defer cancel()
-	go blink()
+	return stageFirmware(storage, t, elf, pb)
+}
 
-	confBlock := 0
-	elfBlock := 0
+// updateBlocks returns the MMC blocks used to update the specified type of
+// firmware: the config block read at boot, the config block for staged
+// updates and the first block of the inactive firmware slot.
+func updateBlocks(t FirmwareType) (confBlock int, stagedBlock int, elfBlock int, err error) {
 	switch t {
 	case Firmware_Applet:
 		confBlock = taConfBlock
+		stagedBlock = taStagedConfBlock
 		if appletLoadedFromBlock == taBlockA {
 			elfBlock = taBlockB
-			log.Print("SM will flash applet to slot B")
 		} else {
-			// If the running applet was loaded from applet slot B, or there was no valid config, store in slot A
+			// If the running applet was loaded from applet slot B, or there was no valid config, use slot A
 			elfBlock = taBlockA
-			log.Print("SM will flash applet to slot A")
 		}
 	case Firmware_OS:
 		confBlock = osConfBlock
+		stagedBlock = osStagedConfBlock
 		if osLoadedFromBlock == osBlockA {
 			elfBlock = osBlockB
-			log.Print("SM will flash OS to slot B")
 		} else {
-			// If the running OS was loaded from OS slot B, or there was no valid config, store in slot A
+			// If the running OS was loaded from OS slot B, or there was no valid config, use slot A
 			elfBlock = osBlockA
-			log.Print("SM will flash OS to slot A")
 		}
 	default:
-		return fmt.Errorf("unknown firmware type %v", t)
+		err = fmt.Errorf("unknown firmware type %v", t)
+	}
+
+	return
+}
+
+// readActive returns the firmware image, as stored, which is booted for the
+// specified type of firmware.
+func readActive(storage Card, t FirmwareType) ([]byte, error) {
+	if storage == nil {
+		return nil, errors.New("missing Storage")
+	}
+
+	confBlock, _, _, err := updateBlocks(t)
+	if err != nil {
+		return nil, err
+	}
+
+	conf, err := readConfig(storage, int64(confBlock))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s config: %v", t, err)
+	}
+
+	return storage.Read(conf.Offset, conf.Size)
+}
+
+// slotName returns a human readable name for the firmware slot starting at
+// the given MMC block.
+func slotName(block int64) string {
+	switch block {
+	case taBlockA, osBlockA:
+		return "A"
+	case taBlockB, osBlockB:
+		return "B"
+	}
+	return fmt.Sprintf("0x%x", block)
+}
+
+// stageFirmware writes elf bytes to the inactive slot for the specified type
+// of firmware, and its config to the staged config block.
+//
+// The staged firmware is not booted until activated with activateFirmware(),
+// this allows updates to be applied at a convenient time.
+//
+// Compressed images are written as received, see compress.Decompress().
+func stageFirmware(storage Card, t FirmwareType, elf []byte, pb config.ProofBundle) error {
+	if storage == nil {
+		return fmt.Errorf("Flashing %s error: missing Storage", t)
 	}
 
+	_, stagedBlock, elfBlock, err := updateBlocks(t)
+	if err != nil {
+		return err
+	}
+
+	slotSize := taSlotNumBlocks * expectedBlockSize
+	if t == Firmware_OS {
+		slotSize = osSlotNumBlocks * expectedBlockSize
+	}
+
+	if len(elf) > slotSize {
+		return fmt.Errorf("%s image (%d bytes) exceeds slot size (%d bytes)", t, len(elf), slotSize)
+	}
+
+	blink, cancel := blinkenLights()
+	defer cancel()
+	go blink()
+
+	log.Printf("SM will flash %s to slot %s", t, slotName(int64(elfBlock)))
+
 	// Convert the signature to an armory-witness-boot format to serialize
 	// all required information for applet loading.
 	conf := &config.Config{
@@ -325,19 +406,141 @@ func flashFirmware(storage Card, t FirmwareType, elf []byte, pb config.ProofBund
 		return err
 	}
 
-	// Flash firmware bytes first before updating config so that in case of any error the unit
-	// will still boot the previous working firmware.
+	// Flash firmware bytes first before writing the staged config so that
+	// in case of any error no incomplete firmware is ever staged.
 	log.Printf("SM flashing %s (%d bytes) @ 0x%x", t, len(elf), elfBlock)
 	if err = flash(storage, elf, elfBlock); err != nil {
 		return fmt.Errorf("%s flashing error: %v", t, err)
 	}
 
+	log.Printf("SM flashing staged %s config (%d bytes) @ 0x%x", t, len(confEnc), stagedBlock)
+	if err = flash(storage, confEnc, stagedBlock); err != nil {
+		return fmt.Errorf("%s signature flashing error: %v", t, err)
+	}
+
+	log.Printf("SM %s update staged", t)
+	return nil
+}
+
+// readStaged returns the config of the staged update for the specified type
+// of firmware, or nil if no update is staged.
+func readStaged(storage Card, t FirmwareType) (*config.Config, error) {
+	if storage == nil {
+		return nil, errors.New("missing Storage")
+	}
+
+	_, stagedBlock, elfBlock, err := updateBlocks(t)
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := storage.Read(int64(stagedBlock)*expectedBlockSize, config.MaxLength)
+	if err != nil {
+		return nil, err
+	}
+
+	conf := &config.Config{}
+	if err := conf.Decode(buf); err != nil {
+		// erased, or never written, config block
+		return nil, nil
+	}
+
+	// Ignore configs left behind by an interrupted activation, these
+	// reference what is now the running firmware slot.
+	if conf.Offset != int64(elfBlock)*expectedBlockSize {
+		return nil, nil
+	}
+
+	return conf, nil
+}
+
+// discardStaged erases the staged config for the specified type of firmware.
+func discardStaged(storage Card, t FirmwareType) error {
+	if storage == nil {
+		return errors.New("missing Storage")
+	}
+
+	_, stagedBlock, _, err := updateBlocks(t)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("SM erasing staged %s config @ 0x%x", t, stagedBlock)
+	return erase(storage, stagedBlock, config.MaxLength/expectedBlockSize)
+}
+
+// activateFirmware re-verifies the staged update for the specified type of
+// firmware and writes its config to the block read at boot, so that it is
+// used from the next reboot onwards.
+func activateFirmware(storage Card, rpmb *RPMB, t FirmwareType) error {
+	conf, err := readStaged(storage, t)
+	if err != nil {
+		return err
+	}
+	if conf == nil {
+		return fmt.Errorf("no %s update staged", t)
+	}
+
+	if err := verifyStaged(storage, rpmb, t, conf); err != nil {
+		return err
+	}
+
+	if err := writeConfig(storage, t, conf); err != nil {
+		return err
+	}
+
+	if err = discardStaged(storage, t); err != nil {
+		log.Printf("SM failed to erase staged %s config: %v", t, err)
+	}
+
+	log.Printf("SM %s update complete", t)
+	return nil
+}
+
+// verifyStaged re-verifies the firmware referenced by a staged config.
+func verifyStaged(storage Card, rpmb *RPMB, t FirmwareType, conf *config.Config) error {
+	// The staged slot is accessible to the applet, ensure that it has not
+	// been tampered with since staging.
+	elf, err := storage.Read(conf.Offset, conf.Size)
+	if err != nil {
+		return fmt.Errorf("failed to read staged %s: %v", t, err)
+	}
+
+	if _, err := verifyFirmware(rpmb, t, newBundle(elf, conf.Bundle)); err != nil {
+		return fmt.Errorf("staged %s verification failed: %v", t, err)
+	}
+
+	return nil
+}
+
+// writeConfig writes the config for the specified type of firmware to the
+// block read at boot.
+func writeConfig(storage Card, t FirmwareType, conf *config.Config) error {
+	confBlock, _, _, err := updateBlocks(t)
+	if err != nil {
+		return err
+	}
+
+	confEnc, err := conf.Encode()
+	if err != nil {
+		return err
+	}
+
+	// Retain the config of the applet slot being replaced, so that it can
+	// be booted again in recovery mode (see switchAppletSlot()).
+	if t == Firmware_Applet {
+		if prev, err := readConfig(storage, int64(confBlock)); err == nil && prev.Offset != conf.Offset {
+			if err := writeFallbackConfig(storage, prev); err != nil {
+				log.Printf("SM failed to store fallback applet config: %v", err)
+			}
+		}
+	}
+
 	log.Printf("SM flashing %s config (%d bytes) @ 0x%x", t, len(confEnc), confBlock)
 	if err = flash(storage, confEnc, confBlock); err != nil {
 		return fmt.Errorf("%s signature flashing error: %v", t, err)
 	}
 
-	log.Printf("SM %s update complete", t)
 	return nil
 }
 
@@ -15,6 +15,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/coreos/go-semver/semver"
@@ -47,15 +48,56 @@ const handler123Cutover = "1.23.0"
 const handler124Cutover = "1.24.0"
 const handler125Cutover = "1.25.0"
 
+// The TamaGo runtime versions supported by the wakeHandler implementations
+// above, from minAppletRuntime (inclusive) to maxAppletRuntime (exclusive).
+// Runtimes older than minAppletRuntime are rejected as the wakeHandler
+// implementations have not been verified against them. maxAppletRuntime must
+// only be raised once the newest implementation has been verified against the
+// new runtime, or a new one has been added.
+//
+// Runtime versions are compared on their major and minor components only.
+// Patch versions are deliberately ignored, as the runtime internals which the
+// wakeHandler implementations depend on only change across releases, and
+// pre-releases (e.g. 1.26.0-rc1) are treated as the release they lead up to.
+const minAppletRuntime = "1.22.0"
+const maxAppletRuntime = "1.26.0"
+
 var (
 	// wHandler is the wakeHandler implementation to be used, 1.25+ by default.
 	wHandler           func(g uint32, p uint32)
 	wHandler123Cutover = *semver.New(handler123Cutover)
 	wHandler124Cutover = *semver.New(handler124Cutover)
 	wHandler125Cutover = *semver.New(handler125Cutover)
+
+	minRuntime = *semver.New(minAppletRuntime)
+	maxRuntime = *semver.New(maxAppletRuntime)
 )
 
-func configureWakeHandler(rtVersion semver.Version) {
+// runtimeRelease returns the major and minor components of a TamaGo runtime
+// version, which determine its internal struct layouts.
+func runtimeRelease(v semver.Version) semver.Version {
+	return semver.Version{Major: v.Major, Minor: v.Minor}
+}
+
+// checkAppletRuntime verifies that the TamaGo runtime version of an applet is
+// within the range supported by the wakeHandler implementations.
+func checkAppletRuntime(rtVersion semver.Version) error {
+	if v := runtimeRelease(rtVersion); v.LessThan(minRuntime) || !v.LessThan(maxRuntime) {
+		return fmt.Errorf("applet TamaGo runtime %s is not supported (supported range: %s <= runtime < %s)", rtVersion.String(), minAppletRuntime, maxAppletRuntime)
+	}
+
+	return nil
+}
+
+// configureWakeHandler selects the wakeHandler implementation for the applet
+// TamaGo runtime version, an error is returned for unsupported runtimes.
+func configureWakeHandler(rtVersion semver.Version) error {
+	if err := checkAppletRuntime(rtVersion); err != nil {
+		return err
+	}
+
+	rtVersion = runtimeRelease(rtVersion)
+
 	switch {
 	case rtVersion.LessThan(wHandler123Cutover):
 		log.Printf("SM Using legacy pre-%s wakeHandler", wHandler123Cutover.String())
@@ -70,6 +112,8 @@ func configureWakeHandler(rtVersion semver.Version) {
 		log.Printf("SM Using OS runtime %s wakeHandler", wHandler125Cutover.String())
 		wHandler = wakeHandlerGo125
 	}
+
+	return nil
 }
 
 func isr() {
@@ -0,0 +1,131 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+	"golang.org/x/mod/sumdb/note"
+
+	"github.com/transparency-dev/armored-witness-common/release/firmware"
+)
+
+// acceptedLogs are the firmware transparency logs trusted by the OS.
+var acceptedLogs []acceptedLog
+
+// acceptedLog represents a firmware transparency log trusted by the OS.
+type acceptedLog struct {
+	// Origin is the log origin string, which identifies the log on the
+	// first line of its checkpoints.
+	Origin string
+	// Verifier verifies the log checkpoint signatures.
+	Verifier note.Verifier
+	// Until, if set, restricts the log to OS releases older than this
+	// version, allowing a log to be retired once releases have moved to
+	// its successor. Applet releases, which are versioned independently,
+	// are not restricted.
+	Until *semver.Version
+}
+
+// parseAcceptedLogs returns the accepted logs, starting with the primary log
+// and followed by any additional ones.
+//
+// Additional logs are whitespace separated entries of comma separated origin,
+// verifier key and optional until version, for example:
+//
+//	example.com/log/0,example.com-log-0+3dd3a2f6+AQ...,1.2.0
+func parseAcceptedLogs(origin string, verifier string, additional string) ([]acceptedLog, error) {
+	entries := []string{origin + "," + verifier}
+	entries = append(entries, strings.Fields(additional)...)
+
+	logs := []acceptedLog{}
+
+	for _, e := range entries {
+		f := strings.Split(e, ",")
+		if len(f) < 2 || len(f) > 3 {
+			return nil, fmt.Errorf("invalid log entry %q", e)
+		}
+
+		v, err := note.NewVerifier(f[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid log verifier %q: %v", f[1], err)
+		}
+
+		l := acceptedLog{
+			Origin:   f[0],
+			Verifier: v,
+		}
+
+		if len(f) == 3 {
+			if l.Until, err = semver.NewVersion(f[2]); err != nil {
+				return nil, fmt.Errorf("invalid log validity %q: %v", f[2], err)
+			}
+		}
+
+		for _, o := range logs {
+			if o.Origin == l.Origin {
+				return nil, fmt.Errorf("duplicate log origin %q", l.Origin)
+			}
+		}
+
+		logs = append(logs, l)
+	}
+
+	// The latest checkpoint of every accepted log must be tracked.
+	if len(logs) > maxLogStates {
+		return nil, fmt.Errorf("at most %d accepted logs are supported, got %d", maxLogStates, len(logs))
+	}
+
+	return logs, nil
+}
+
+// selectLog returns the accepted log matching the origin line of a
+// checkpoint.
+func selectLog(checkpoint []byte) (*acceptedLog, error) {
+	origin, _, _ := bytes.Cut(checkpoint, []byte("\n"))
+
+	for i := range acceptedLogs {
+		if acceptedLogs[i].Origin == string(origin) {
+			return &acceptedLogs[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown log origin %q", origin)
+}
+
+// bundleVerifier returns the bundle verifier for the given type of firmware,
+// configured for the accepted log which issued the checkpoint.
+func bundleVerifier(t FirmwareType, checkpoint []byte) (bv firmware.BundleVerifier, l *acceptedLog, err error) {
+	switch t {
+	case Firmware_Applet:
+		bv = AppletBundleVerifier
+	case Firmware_OS:
+		bv = OSBundleVerifier
+	default:
+		return bv, nil, fmt.Errorf("unknown firmware type %v", t)
+	}
+
+	if l, err = selectLog(checkpoint); err != nil {
+		return
+	}
+
+	bv.LogOrigin = l.Origin
+	bv.LogVerifer = l.Verifier
+
+	return
+}
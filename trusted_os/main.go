@@ -15,15 +15,14 @@
 package main
 
 import (
-	"bytes"
 	"crypto/sha256"
 	_ "embed"
-	"encoding/gob"
 	"fmt"
 	"log"
 	"os"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/coreos/go-semver/semver"
@@ -36,7 +35,11 @@ import (
 
 	// for now just test compilation of these
 	"github.com/transparency-dev/armored-witness-common/release/firmware"
+	"github.com/transparency-dev/armored-witness-os/api"
+	"github.com/transparency-dev/armored-witness-os/internal/compress"
 	_ "github.com/transparency-dev/armored-witness-os/internal/hab"
+	"github.com/transparency-dev/armored-witness-os/internal/quorum"
+	"github.com/transparency-dev/armored-witness-os/internal/witness"
 	_ "github.com/transparency-dev/armored-witness-os/rpmb"
 )
 
@@ -52,8 +55,15 @@ var (
 	LogVerifier            string
 	LogOrigin              string
 	AppletManifestVerifier string
-	OSManifestVerifier1    string
-	OSManifestVerifier2    string
+	// AppletManifestThreshold is the number of AppletManifestVerifier keys
+	// required to sign applet manifests, all keys are required when unset.
+	AppletManifestThreshold string
+	OSManifestVerifier1     string
+	OSManifestVerifier2     string
+	RevocationVerifier      string
+	AdditionalLogs          string
+	WitnessVerifiers        string
+	WitnessThreshold        string
 )
 
 var (
@@ -71,9 +81,20 @@ var (
 	// loadedAppletVersion is taken from the manifest used to verify the
 	// applet.
 	loadedAppletVersion semver.Version
+	// loadedAppletSigners lists the keys which signed the manifest used to
+	// verify the applet.
+	loadedAppletSigners []string
 
 	AppletBundleVerifier firmware.BundleVerifier
 	OSBundleVerifier     firmware.BundleVerifier
+	// WitnessPolicy is the witness cosignature policy which all firmware
+	// log checkpoints must satisfy.
+	WitnessPolicy *witness.Policy
+
+	// appletHalt is held by operations which require the Trusted Applet to
+	// remain stopped, it prevents the applet execution loop from restarting
+	// it.
+	appletHalt sync.Mutex
 )
 
 // A Trusted Applet can be embedded for testing purposes with QEMU.
@@ -159,7 +180,7 @@ func main() {
 	rpc := &RPC{
 		RPMB:        rpmb,
 		Storage:     Storage,
-		Diversifier: sha256.Sum256([]byte(AppletManifestVerifier)),
+		Diversifier: appletDiversifier(AppletManifestVerifier),
 	}
 
 	ctl := &controlInterface{
@@ -183,21 +204,65 @@ func main() {
 		log.Printf("Failed to determine OS MMC block (no OS installed?): %v", err)
 	}
 
+	if reboot, err := completeCombined(Storage); err != nil {
+		log.Printf("SM failed to complete combined update: %v", err)
+	} else if reboot {
+		log.Printf("SM rebooting into updated OS")
+		usbarmory.Reset()
+	}
+
 	log.Printf("SM log verification pub: %s", LogVerifier)
 	logVerifier, err := note.NewVerifier(LogVerifier)
 	if err != nil {
 		log.Fatalf("SM invalid AppletLogVerifier: %v", err)
 	}
+	acceptedLogs, err = parseAcceptedLogs(LogOrigin, LogVerifier, AdditionalLogs)
+	if err != nil {
+		log.Fatalf("SM invalid accepted logs: %v", err)
+	}
+	for _, l := range acceptedLogs {
+		log.Printf("SM accepted log: %s (until %v)", l.Origin, l.Until)
+	}
 	log.Printf("SM applet verification pub: %s", AppletManifestVerifier)
-	AppletBundleVerifier, err = createBundleVerifier(LogOrigin, logVerifier, []string{AppletManifestVerifier})
+	appletManifestVerifiers := strings.Fields(AppletManifestVerifier)
+	AppletBundleVerifier, err = createBundleVerifier(LogOrigin, logVerifier, appletManifestVerifiers)
 	if err != nil {
 		log.Fatalf("SM failed to create applet bundle verifier: %v", err)
 	}
+	appletManifestThreshold, err = quorum.Parse(AppletManifestThreshold, len(appletManifestVerifiers))
+	if err == nil {
+		err = quorum.Check(appletManifestThreshold, len(appletManifestVerifiers))
+	}
+	if err != nil {
+		log.Fatalf("SM invalid AppletManifestThreshold: %v", err)
+	}
+	log.Printf("SM applet manifest policy: %d of %d", appletManifestThreshold, len(appletManifestVerifiers))
 	OSBundleVerifier, err = createBundleVerifier(LogOrigin, logVerifier, []string{OSManifestVerifier1, OSManifestVerifier2})
 	if err != nil {
 		log.Fatalf("SM failed to create OS bundle verifier: %v", err)
 	}
 
+	if len(RevocationVerifier) > 0 {
+		log.Printf("SM revocation list verification pub: %s", RevocationVerifier)
+		if revocationVerifier, err = note.NewVerifier(RevocationVerifier); err != nil {
+			log.Fatalf("SM invalid RevocationVerifier: %v", err)
+		}
+	}
+
+	if l, err := loadRevocations(Storage, rpmb); err != nil {
+		log.Printf("SM revocation list error, all firmware will be rejected: %v", err)
+		revocationsErr = err
+	} else {
+		log.Printf("SM revocation list version %d (%d revoked keys)", l.Version, len(l.Revoked))
+		revocations = l
+	}
+
+	WitnessPolicy, err = witness.ParsePolicy(WitnessVerifiers, WitnessThreshold)
+	if err != nil {
+		log.Fatalf("SM invalid witness policy: %v", err)
+	}
+	log.Printf("SM witness policy: %d of %d", WitnessPolicy.Threshold, len(WitnessPolicy.Verifiers))
+
 	if v, err := semver.NewVersion(Version); err != nil {
 		log.Printf("Failed to parse OS version %q: %v", Version, err)
 	} else {
@@ -207,15 +272,18 @@ func main() {
 	var ta *firmware.Bundle
 	if len(taELF) > 0 && len(taProofBundle) > 0 {
 		// Handle embedded applet & proof.
-		dec := gob.NewDecoder(bytes.NewBuffer(taProofBundle))
-		ta = &firmware.Bundle{}
-		if err := dec.Decode(ta); err != nil {
-			log.Printf("SM invalid embedded proof bundle: %v", err)
+		pb, err := api.ParseProofBundle(taProofBundle)
+		if err != nil {
+			enterRecovery(fmt.Sprintf("invalid embedded proof bundle, %v", err))
+		} else if elf, err := compress.Decompress(taELF); err != nil {
+			enterRecovery(fmt.Sprintf("invalid embedded applet, %v", err))
+		} else {
+			b := pb.Bundle(elf)
+			ta = &b
 		}
-		ta.Firmware = taELF
 	} else {
 		if ta, err = read(Storage); err != nil {
-			log.Printf("SM could not load applet, %v", err)
+			enterRecovery(fmt.Sprintf("could not load applet, %v", err))
 		}
 	}
 
@@ -223,39 +291,57 @@ func main() {
 		go func() {
 			for {
 				log.Print("SM Verifying applet bundle")
-				manifest, err := AppletBundleVerifier.Verify(*ta)
+				// An applet failing verification is never executed,
+				// the OS enters recovery mode instead so that a
+				// replacement can be installed over the control
+				// interface (see recovery.go), rather than running
+				// an unverified applet or leaving the device
+				// unmanageable.
+				manifest, err := verifyFirmware(rpmb, Firmware_Applet, *ta)
 				if err != nil {
-					log.Printf("SM applet verification error, %v", err)
+					enterRecovery(fmt.Sprintf("applet verification error, %v", err))
+					return
 				}
 				loadedAppletVersion = manifest.Git.TagName
+				loadedAppletSigners = manifestSigners(Firmware_Applet, ta.Manifest)
 				loadedAppletRuntime := manifest.Build.TamagoVersion
 				log.Printf("SM Loaded applet version %s (with TamaGo runtime %s)", loadedAppletVersion.String(), loadedAppletRuntime.String())
 
-				configureWakeHandler(loadedAppletRuntime)
+				if err := configureWakeHandler(loadedAppletRuntime); err != nil {
+					enterRecovery(fmt.Sprintf("refusing to load applet, %v", err))
+					return
+				}
 
 				usbarmory.LED("white", true)
 
+				// wait for any operation requiring the applet to be halted
+				appletHalt.Lock()
+				appletHalt.Unlock()
+
+				// The applet execution context is only returned once
+				// loaded, any error is otherwise an execution one.
 				appletCtx, err := loadApplet(ta.Firmware, ctl)
+				if appletCtx == nil {
+					enterRecovery(fmt.Sprintf("applet load error, %v", err))
+					return
+				}
+
 				if err != nil {
 					log.Printf("SM applet execution error, %v", err)
 				}
 
 				<-appletCtx.Done()
+
+				appletHalt.Lock()
 				if err := storeAppletCrashLog(Storage, getConsoleLogs()); err != nil {
 					log.Printf("Failed to store ringbuffer logs: %v", err)
 				}
+				appletHalt.Unlock()
 			}
 		}()
 	}
 
-	go func() {
-		l := true
-		for {
-			usbarmory.LED("white", l)
-			l = !l
-			time.Sleep(500 * time.Millisecond)
-		}
-	}()
+	go statusLights()
 
 	if imx6ul.Native {
 		// start USB control interface
@@ -266,6 +352,35 @@ func main() {
 	arm.ServiceInterrupts(isr)
 }
 
+// haltApplet stops the Trusted Applet, if running, and prevents the applet
+// execution loop from restarting it. It is meant to be used ahead of
+// operations which are followed by a reboot.
+func haltApplet(r *RPC) {
+	appletHalt.Lock()
+
+	if r.Ctx != nil {
+		r.Ctx.Stop()
+		<-r.Ctx.Done()
+	}
+}
+
+// appletDiversifier returns the diversifier of keys derived for the applet,
+// which include the witness identity.
+//
+// Only the first, primary, applet manifest verifier key is used so that the
+// derived keys do not change as other signers are added, removed or rotated,
+// this matches the diversifier of builds with a single verifier key. The
+// primary key must therefore always be listed first in APPLET_PUBLIC_KEY.
+func appletDiversifier(verifiers string) [32]byte {
+	var primary string
+
+	if keys := strings.Fields(verifiers); len(keys) > 0 {
+		primary = keys[0]
+	}
+
+	return sha256.Sum256([]byte(primary))
+}
+
 func createBundleVerifier(logOrigin string, logVerifier note.Verifier, manifestVerifiers []string) (firmware.BundleVerifier, error) {
 	vs := []note.Verifier{}
 	for _, v := range manifestVerifiers {
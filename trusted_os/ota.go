@@ -0,0 +1,218 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/transparency-dev/armored-witness-os/api"
+	"github.com/transparency-dev/armored-witness-os/api/rpc"
+)
+
+// otaIdleTimeout is the time after which an upload which has not received
+// any chunk can be taken over by another client.
+const otaIdleTimeout = time.Minute
+
+// otaClient identifies the source of firmware update chunks.
+type otaClient int
+
+const (
+	// otaApplet identifies updates sent through the applet RPCs.
+	otaApplet otaClient = iota
+	// otaControl identifies updates sent through the USB control
+	// interface.
+	otaControl
+)
+
+func (c otaClient) String() string {
+	if c == otaControl {
+		return "control interface"
+	}
+	return "applet"
+}
+
+// The upload buffers are shared by the applet RPCs and the USB control
+// interface, rather than allocated for each, as the OS RAM (224MB, see
+// mem.go) must hold them along with the copies made while staging. At most
+// the OS and applet images (2 * api.MaxFirmwareSize) and an update package
+// are buffered, staging adds up to two copies of an image, for delta
+// reconstruction and decompression.
+var (
+	// otaLock serializes access to the upload buffers, and to the staging
+	// slots which are written both by the applet RPCs and by the USB
+	// control interface.
+	otaLock sync.Mutex
+
+	// osUpload holds the OS firmware image being received.
+	osUpload = &otaBuffer{limit: otaLimit}
+	// appletUpload holds the applet firmware image being received.
+	appletUpload = &otaBuffer{limit: otaLimit}
+	// packageUpload holds the update package being received.
+	packageUpload = &otaBuffer{limit: api.MaxUpdatePackageSize}
+
+	// combinedStaged tracks the firmware types staged by the combined update
+	// in progress, open is set from its first chunk until it is either
+	// activated or discarded.
+	combinedStaged struct {
+		open   bool
+		os     bool
+		applet bool
+	}
+)
+
+// otaBuffer accumulates a firmware image sent as a sequence of chunks.
+type otaBuffer struct {
+	// seq is the sequence number expected for the next chunk.
+	seq uint
+	// buf holds the firmware image bytes received so far.
+	buf []byte
+	// limit is the maximum length of the firmware image.
+	limit int
+	// client is the source of the upload in progress.
+	client otaClient
+	// updated is the time the last chunk was received.
+	updated time.Time
+}
+
+// uploadFor returns the firmware upload buffer for the given firmware type.
+func uploadFor(t FirmwareType) (*otaBuffer, error) {
+	switch t {
+	case Firmware_Applet:
+		return appletUpload, nil
+	case Firmware_OS:
+		return osUpload, nil
+	}
+	return nil, fmt.Errorf("unknown firmware type %v", t)
+}
+
+// installStatus returns the progress of the firmware update, for the given
+// firmware type, which the client has in progress.
+func installStatus(c otaClient, t FirmwareType, status *rpc.FirmwareUpdateStatus) error {
+	otaLock.Lock()
+	defer otaLock.Unlock()
+
+	o, err := uploadFor(t)
+	if err != nil {
+		return err
+	}
+
+	if err := o.claim(c, false); err != nil {
+		return err
+	}
+
+	if o.client != c {
+		// nothing in progress for this client
+		*status = rpc.FirmwareUpdateStatus{}
+		return nil
+	}
+
+	*status = o.status()
+
+	return nil
+}
+
+// claim returns an error if an upload from another client is in progress,
+// which is only the case until it completes, fails or is idle for
+// otaIdleTimeout. Otherwise, if take is set, the buffer is assigned to the
+// client, discarding any idle upload from another one.
+func (o *otaBuffer) claim(c otaClient, take bool) error {
+	if c != o.client && len(o.buf) > 0 {
+		if time.Since(o.updated) < otaIdleTimeout {
+			return fmt.Errorf("%s upload in progress", o.client)
+		}
+
+		if take {
+			o.reset()
+		}
+	}
+
+	if take {
+		o.client = c
+	}
+
+	return nil
+}
+
+// append adds the firmware image chunk contained in u to the buffer.
+//
+// A chunk with a zero Sequence starts a fresh upload, discarding any previous
+// partial attempt. Any other chunk must carry the next expected sequence
+// number and an offset contiguous with the previous chunk, otherwise an error
+// is returned and the buffer is left untouched so that the upload can be
+// resumed from the point reported by status().
+//
+// A zero offset on the next expected chunk is taken as contiguous, as legacy
+// clients do not set it.
+//
+// Chunks which have already been received (e.g. re-sent after a lost
+// response) are accepted, without any effect, as long as they are identical
+// to the buffered data.
+func (o *otaBuffer) append(u *rpc.FirmwareUpdate) error {
+	if len(u.Digest) > 0 {
+		if h := sha256.Sum256(u.Image); !bytes.Equal(h[:], u.Digest) {
+			return fmt.Errorf("chunk %d digest mismatch (%x != %x)", u.Sequence, h, u.Digest)
+		}
+	}
+
+	if u.Sequence == 0 {
+		// Dump previous partial attempts
+		o.seq = 0
+		o.buf = make([]byte, 0, len(u.Image))
+	}
+
+	offset := u.Offset
+	if offset == 0 && u.Sequence == o.seq {
+		offset = int64(len(o.buf))
+	}
+
+	switch {
+	case u.Sequence < o.seq:
+		end := offset + int64(len(u.Image))
+		if offset < 0 || end > int64(len(o.buf)) || !bytes.Equal(o.buf[offset:end], u.Image) {
+			return fmt.Errorf("chunk %d conflicts with previously received data", u.Sequence)
+		}
+		return nil
+	case u.Sequence > o.seq:
+		return fmt.Errorf("unexpected chunk sequence %d, expected %d", u.Sequence, o.seq)
+	case offset != int64(len(o.buf)):
+		return fmt.Errorf("unexpected chunk offset %d, expected %d", offset, len(o.buf))
+	case len(o.buf)+len(u.Image) > o.limit:
+		return fmt.Errorf("firmware image exceeds %d bytes", o.limit)
+	}
+
+	o.buf = append(o.buf, u.Image...)
+	o.seq++
+	o.updated = time.Now()
+
+	return nil
+}
+
+// status returns the sequence number and offset expected for the next chunk.
+func (o *otaBuffer) status() rpc.FirmwareUpdateStatus {
+	return rpc.FirmwareUpdateStatus{
+		Sequence: o.seq,
+		Offset:   int64(len(o.buf)),
+	}
+}
+
+// reset discards any buffered firmware image.
+func (o *otaBuffer) reset() {
+	o.seq = 0
+	o.buf = nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	usbarmory "github.com/usbarmory/tamago/board/usbarmory/mk2"
+
+	"github.com/transparency-dev/armored-witness-boot/config"
+)
+
+// recovery tracks whether the device is in recovery mode, which is entered
+// when the installed applet cannot be verified or loaded.
+//
+// In recovery mode no applet is executed, the LEDs signal the condition and
+// the USB control interface accepts commands to boot the applet in the other
+// slot or to install a new one.
+var recovery struct {
+	sync.Mutex
+
+	// reason describes why recovery mode was entered, it is empty when
+	// not in recovery mode.
+	reason string
+}
+
+// enterRecovery switches the device to recovery mode.
+func enterRecovery(reason string) {
+	recovery.Lock()
+	defer recovery.Unlock()
+
+	log.Printf("SM entering recovery mode, %s", reason)
+	recovery.reason = reason
+}
+
+// inRecovery returns whether the device is in recovery mode, and the reason
+// for it.
+func inRecovery() (bool, string) {
+	recovery.Lock()
+	defer recovery.Unlock()
+
+	return len(recovery.reason) > 0, recovery.reason
+}
+
+// statusLights signals the device state with the LEDs: a slowly blinking
+// white LED in normal operation, a double blinking blue LED in recovery mode.
+func statusLights() {
+	l := true
+
+	for {
+		if ok, _ := inRecovery(); ok {
+			usbarmory.LED("white", false)
+
+			for i := 0; i < 2; i++ {
+				usbarmory.LED("blue", true)
+				time.Sleep(100 * time.Millisecond)
+				usbarmory.LED("blue", false)
+				time.Sleep(100 * time.Millisecond)
+			}
+
+			time.Sleep(600 * time.Millisecond)
+			continue
+		}
+
+		usbarmory.LED("white", l)
+		l = !l
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// writeFallbackConfig stores the config of a previously active applet slot.
+func writeFallbackConfig(storage Card, conf *config.Config) error {
+	confEnc, err := conf.Encode()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("SM flashing fallback applet config (%d bytes) @ 0x%x", len(confEnc), taFallbackConfBlock)
+	return flash(storage, confEnc, taFallbackConfBlock)
+}
+
+// switchAppletSlot re-verifies the applet in the previously active slot and
+// writes its config to the block read at boot, so that it is used from the
+// next reboot onwards.
+//
+// The previously active slot is also the one used to stage updates, it is
+// therefore rejected by verification if it has since been overwritten.
+func switchAppletSlot(storage Card, rpmb *RPMB) error {
+	if storage == nil {
+		return errors.New("missing Storage")
+	}
+
+	conf, err := readConfig(storage, taFallbackConfBlock)
+	if err != nil {
+		return fmt.Errorf("no previous applet slot available: %v", err)
+	}
+
+	slot := slotName(conf.Offset / expectedBlockSize)
+
+	if active, err := readConfig(storage, taConfBlock); err == nil && active.Offset == conf.Offset {
+		return fmt.Errorf("applet slot %s is already active", slot)
+	}
+
+	elf, err := storage.Read(conf.Offset, conf.Size)
+	if err != nil {
+		return fmt.Errorf("failed to read applet slot %s: %v", slot, err)
+	}
+
+	manifest, err := verifyFirmware(rpmb, Firmware_Applet, newBundle(elf, conf.Bundle))
+	if err != nil {
+		return fmt.Errorf("applet slot %s verification failed: %v", slot, err)
+	}
+
+	if err := checkRunningCompatibility(Firmware_Applet, conf.Bundle.Manifest, manifest.Git.TagName); err != nil {
+		return err
+	}
+
+	log.Printf("SM switching to applet slot %s", slot)
+
+	return writeConfig(storage, Firmware_Applet, conf)
+}
@@ -0,0 +1,62 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/transparency-dev/armored-witness-os/api/rpc"
+	"github.com/transparency-dev/armored-witness-os/rpmb"
+)
+
+// factoryReset erases all witness state so that the device can be
+// re-deployed, this includes:
+//   - the storage region used by the applet for its own data, including its
+//     configuration (see rpc.AppletDataBlock)
+//   - the applet crash log
+//   - the RPMB sector allocated to the applet
+//
+// Installed firmware, and its rollback protection, is left untouched.
+//
+// The Trusted Applet must not be running when this function is invoked.
+func factoryReset(r *RPC) error {
+	if r.Storage == nil {
+		return errors.New("missing Storage")
+	}
+
+	blink, cancel := blinkenLights()
+	defer cancel()
+	go blink()
+
+	log.Printf("SM erasing applet data (%d blocks @ 0x%x)", rpc.AppletDataNumBlocks, rpc.AppletDataBlock)
+	if err := erase(r.Storage, rpc.AppletDataBlock, rpc.AppletDataNumBlocks); err != nil {
+		return fmt.Errorf("failed to erase applet data: %v", err)
+	}
+
+	log.Printf("SM erasing applet crash log (%d blocks @ 0x%x)", crashLogNumBlocks, crashLogBlock)
+	if err := erase(r.Storage, crashLogBlock, crashLogNumBlocks); err != nil {
+		return fmt.Errorf("failed to erase applet crash log: %v", err)
+	}
+
+	log.Printf("SM erasing applet RPMB sector")
+	if err := r.RPMB.transfer(taUserSector, make([]byte, rpmb.FrameLength/2), nil, true); err != nil {
+		return fmt.Errorf("failed to erase applet RPMB sector: %v", err)
+	}
+
+	log.Printf("SM factory reset complete")
+	return nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+
+	"golang.org/x/mod/sumdb/note"
+
+	"github.com/transparency-dev/armored-witness-os/internal/revocation"
+)
+
+var (
+	// revocationVerifier verifies revocation list signatures, revocation
+	// lists are not supported when unset.
+	revocationVerifier note.Verifier
+
+	// revocations is the manifest signer revocation list in effect.
+	revocations = &revocation.List{}
+	// revocationsErr is set when the stored revocation list fails its
+	// integrity check, in which case all firmware is rejected.
+	revocationsErr error
+)
+
+// checkRevocations verifies that none of the signatures of a verified note
+// have been made with a revoked key.
+func checkRevocations(n *note.Note) error {
+	if revocationsErr != nil {
+		return revocationsErr
+	}
+
+	for _, sig := range n.Sigs {
+		if revocations.IsRevoked(sig.Name, sig.Hash) {
+			return fmt.Errorf("key %s+%08x has been revoked (revocation list version %d)", sig.Name, sig.Hash, revocations.Version)
+		}
+	}
+
+	return nil
+}
+
+// readRevocationState returns the version and digest of the revocation list
+// anchored in RPMB.
+func readRevocationState(r *RPMB) (version uint64, digest [sha256.Size]byte, err error) {
+	buf := make([]byte, 8+sha256.Size)
+
+	if err = r.transfer(revocationSector, buf, nil, false); err != nil {
+		return
+	}
+
+	version = binary.BigEndian.Uint64(buf)
+	copy(digest[:], buf[8:])
+
+	return
+}
+
+// writeRevocationState anchors the version and digest of the revocation list
+// in RPMB.
+func writeRevocationState(r *RPMB, l *revocation.List) error {
+	buf := make([]byte, 8+sha256.Size)
+
+	binary.BigEndian.PutUint64(buf, l.Version)
+	copy(buf[8:], l.Digest[:])
+
+	return r.transfer(revocationSector, buf, nil, true)
+}
+
+// readRevocations reads the revocation list stored on internal storage, it
+// returns an empty list if none is stored.
+//
+// Storage contents which cannot be parsed are also treated as an empty list
+// while no list has been anchored (see revocation.Decode()), as the
+// revocation block is not initialized on provisioning.
+func readRevocations(storage Card, anchored uint64) (*revocation.List, error) {
+	buf, err := storage.Read(revocationBlock*expectedBlockSize, revocationNumBlocks*expectedBlockSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return revocation.Decode(buf, revocationVerifier, anchored)
+}
+
+// loadRevocations reads the revocation list stored on internal storage and,
+// when rollback protection is available, verifies it against the version and
+// digest anchored in RPMB.
+//
+// A list with a version greater than the anchored one is the result of an
+// interrupted update (see updateRevocations()) and is anchored, any other
+// mismatch is an integrity failure.
+func loadRevocations(storage Card, r *RPMB) (*revocation.List, error) {
+	if storage == nil {
+		return &revocation.List{}, nil
+	}
+
+	var version uint64
+	var digest [sha256.Size]byte
+	var err error
+
+	if rollbackProtection() {
+		if version, digest, err = readRevocationState(r); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := readRevocations(storage, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if !rollbackProtection() {
+		return l, nil
+	}
+
+	switch {
+	case l.Version == version && (l.Digest == digest || version == 0):
+		return l, nil
+	case l.Version > version:
+		log.Printf("SM anchoring revocation list version %d", l.Version)
+		return l, writeRevocationState(r, l)
+	}
+
+	return nil, fmt.Errorf("revocation list integrity failure (version %d, expected %d)", l.Version, version)
+}
+
+// updateRevocations verifies a new revocation list, which must have a version
+// greater than the one in effect, and stores it.
+//
+// Following an integrity failure the list anchored in RPMB, or any newer one,
+// is accepted.
+func updateRevocations(storage Card, r *RPMB, buf []byte) (*revocation.List, error) {
+	if storage == nil {
+		return nil, errors.New("missing Storage")
+	}
+
+	l, err := revocation.Parse(buf, revocationVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if revocationsErr == nil && l.Version <= revocations.Version {
+		return nil, fmt.Errorf("revocation list version %d is not newer than %d", l.Version, revocations.Version)
+	}
+
+	if rollbackProtection() {
+		version, digest, err := readRevocationState(r)
+		if err != nil {
+			return nil, err
+		}
+
+		// The anchored list can be re-installed to recover from an
+		// integrity failure.
+		if l.Version < version || (l.Version == version && l.Digest != digest) {
+			return nil, fmt.Errorf("revocation list version %d is not newer than %d", l.Version, version)
+		}
+	}
+
+	if len(buf) > revocationNumBlocks*expectedBlockSize-4 {
+		return nil, errors.New("revocation list too large")
+	}
+
+	rec := revocation.Encode(buf)
+
+	// The list is stored before anchoring it in RPMB, so that an
+	// interrupted update is completed by loadRevocations().
+	log.Printf("SM flashing revocation list version %d (%d bytes) @ 0x%x", l.Version, len(rec), revocationBlock)
+	if err := flash(storage, rec, revocationBlock); err != nil {
+		return nil, fmt.Errorf("revocation list flashing error: %v", err)
+	}
+
+	if rollbackProtection() {
+		if err := writeRevocationState(r, l); err != nil {
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
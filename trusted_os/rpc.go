@@ -15,9 +15,11 @@
 package main
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/sha256"
 	"errors"
+	"fmt"
 	"log"
 	"net"
 	"strings"
@@ -30,6 +32,7 @@ import (
 
 	"github.com/transparency-dev/armored-witness-os/api"
 	"github.com/transparency-dev/armored-witness-os/api/rpc"
+	"github.com/transparency-dev/armored-witness-os/internal/delta"
 	"github.com/transparency-dev/armored-witness-os/internal/hab"
 )
 
@@ -142,12 +145,20 @@ func (r *RPC) CardInfo(_ any, info *usdhc.CardInfo) error {
 	return nil
 }
 
-// WriteBlocks transfers full blocks of data to the storage media.
+// WriteBlocks transfers full blocks of data to the storage media, within the
+// applet data region (see rpc.AppletDataBlock) so that all state persisted by
+// the applet is erased on factory reset.
 func (r *RPC) WriteBlocks(xfer rpc.WriteBlocks, _ *bool) error {
 	if r.Storage == nil {
 		return errors.New("missing Storage")
 	}
 
+	blocks := (len(xfer.Data) + expectedBlockSize - 1) / expectedBlockSize
+
+	if xfer.LBA < rpc.AppletDataBlock || xfer.LBA+blocks > rpc.AppletDataBlock+rpc.AppletDataNumBlocks {
+		return fmt.Errorf("write of %d blocks @ 0x%x outside of applet data region", blocks, xfer.LBA)
+	}
+
 	return r.Storage.WriteBlocks(xfer.LBA, xfer.Data)
 }
 
@@ -234,77 +245,464 @@ func (r *RPC) GetInstalledVersions(_ *any, v *rpc.InstalledVersions) error {
 //   - An RPC call with the Sequence field set to zero indicates a fresh attempt to install firmware.
 //   - If firmware is being sent in chunks via multiple RPC calls, each subsequent RPC call should:
 //     1. increment the Sequence field by 1 each time.
-//     2. Pass a chunk of firmware image which is contiguous with the previous chunk.
-//   - An RPC call with the Proof set to a non-zero value indicates that all firmware chunks have been sent.
-//     This will cause the firmware update to be finalised, and if successful, this RPC will not
-//     return and the device will reboot.
+//     2. Pass a chunk of firmware image which is contiguous with the previous chunk, with its
+//     position within the image set in the Offset field.
+//     3. Optionally set the Digest field to the SHA256 of the chunk.
+//   - Chunks which are out of sequence, not contiguous, or fail digest verification are rejected,
+//     the InstallStatus RPC reports the next chunk expected which allows interrupted uploads to be
+//     resumed.
+//   - An RPC call with the ProofBundle, or the legacy Proof, set to a non-zero value indicates that all
+//     firmware chunks have been sent.
+//     This will cause the firmware update to be finalised, and if successful, the applet will be
+//     stopped and the device will reboot.
+//   - The firmware image can be a binary delta against the active firmware image, in which case
+//     the DeltaBase field must be set on the last chunk. The complete image is reconstructed before
+//     verification.
+//
+// InstallOS is equivalent to StageOS followed by ActivateStaged.
 func (r *RPC) InstallOS(b *rpc.FirmwareUpdate, _ *bool) error {
-	if b.Sequence == 0 {
-		// Dump previous partial attempts
-		osFirmwareBuffer = make([]byte, 0, len(b.Image))
+	if staged, err := r.stage(otaApplet, Firmware_OS, b, &rpc.FirmwareUpdateStatus{}, false); err != nil || !staged {
+		return err
+	}
+
+	return r.ActivateStaged(Firmware_OS, nil)
+}
+
+// InstallApplet updates the Applet to the version contained in the firmware bundle.
+//
+// The firmware image is sent as described for InstallOS, it can optionally be
+// gzip compressed in which case the manifest must commit to the decompressed
+// image.
+//
+// InstallApplet is equivalent to StageApplet followed by ActivateStaged.
+func (r *RPC) InstallApplet(b *rpc.FirmwareUpdate, _ *bool) error {
+	if staged, err := r.stage(otaApplet, Firmware_Applet, b, &rpc.FirmwareUpdateStatus{}, false); err != nil || !staged {
+		return err
 	}
-	// Extend our firmware buffer
-	osFirmwareBuffer = append(osFirmwareBuffer, b.Image...)
+
+	return r.ActivateStaged(Firmware_Applet, nil)
+}
+
+// StageOS verifies and writes an OS update to the inactive slot, without
+// rebooting, the update is only booted once activated with ActivateStaged.
+//
+// The firmware image is sent as described for InstallOS, any previously
+// staged OS update is replaced.
+func (r *RPC) StageOS(b *rpc.FirmwareUpdate, status *rpc.FirmwareUpdateStatus) error {
+	_, err := r.stage(otaApplet, Firmware_OS, b, status, false)
+	return err
+}
+
+// StageApplet verifies and writes an applet update to the inactive slot,
+// without rebooting, the update is only booted once activated with
+// ActivateStaged.
+//
+// The firmware image is sent as described for InstallOS, any previously
+// staged applet update is replaced.
+func (r *RPC) StageApplet(b *rpc.FirmwareUpdate, status *rpc.FirmwareUpdateStatus) error {
+	_, err := r.stage(otaApplet, Firmware_Applet, b, status, false)
+	return err
+}
+
+// InstallPackage updates the OS or applet to the firmware contained in an
+// update package (see api.UpdatePackage).
+//
+// The serialized package is sent in chunks, in the Image field, as described
+// for InstallOS. The package is complete once the length declared in its
+// header has been received, at which point its firmware image is verified and
+// staged as it would be by InstallOS or InstallApplet. Besides the chunk
+// fields only ConsistencyProof is used, when required it must be set on the
+// last chunk.
+//
+// InstallPackage is equivalent to StagePackage followed by ActivateStaged.
+func (r *RPC) InstallPackage(b *rpc.FirmwareUpdate, status *rpc.FirmwareUpdateStatus) error {
+	t, staged, err := r.stagePackage(b, status)
+	if err != nil || !staged {
+		return err
+	}
+
+	return r.ActivateStaged(t, nil)
+}
+
+// StagePackage verifies and writes the firmware contained in an update
+// package to the inactive slot, without rebooting, the update is only booted
+// once activated with ActivateStaged.
+//
+// The update package is sent as described for InstallPackage.
+func (r *RPC) StagePackage(b *rpc.FirmwareUpdate, status *rpc.FirmwareUpdateStatus) error {
+	_, _, err := r.stagePackage(b, status)
+	return err
+}
+
+// stagePackage receives an update package chunk, once the package is
+// complete its firmware image is verified and staged to internal storage.
+func (r *RPC) stagePackage(b *rpc.FirmwareUpdate, status *rpc.FirmwareUpdateStatus) (FirmwareType, bool, error) {
+	p, err := receivePackage(b, status)
+	if err != nil || p == nil {
+		return 0, false, err
+	}
+
+	t := FirmwareType(p.Type)
+
+	log.Printf("SM received %s %s update package", t, p.Version)
+
+	u := &rpc.FirmwareUpdate{
+		Image:            p.Firmware,
+		DeltaBase:        p.DeltaBase,
+		Proof:            p.Proof.Config(),
+		ConsistencyProof: b.ConsistencyProof,
+	}
+
+	staged, err := r.stage(otaApplet, t, u, &rpc.FirmwareUpdateStatus{}, false)
+
+	return t, staged, err
+}
+
+// receivePackage receives an update package chunk, the update package is
+// returned once complete.
+func receivePackage(b *rpc.FirmwareUpdate, status *rpc.FirmwareUpdateStatus) (*api.UpdatePackage, error) {
+	otaLock.Lock()
+	defer otaLock.Unlock()
+
+	o := packageUpload
+
+	err := o.append(b)
 	b.Image = nil
+	*status = o.status()
 
-	// Return early if we're don't yet have the full image.
-	if len(b.Proof.Checkpoint) == 0 {
+	if err != nil || len(o.buf) < api.UpdatePackageHeaderSize {
+		return nil, err
+	}
+
+	n, err := api.UpdatePackageSize(o.buf)
+	if err != nil {
+		o.reset()
+		return nil, err
+	}
+
+	switch {
+	case len(o.buf) < n:
+		return nil, nil
+	case len(o.buf) > n:
+		o.reset()
+		return nil, fmt.Errorf("update package exceeds declared length %d", n)
+	}
+
+	defer o.reset()
+
+	return api.ParseUpdatePackage(o.buf)
+}
+
+// InstallCombined updates both the OS and applet, to the versions contained in
+// their respective firmware bundles, as a single transaction.
+//
+// Each firmware image is sent as described for InstallOS, chunks for either
+// image can be sent in any order. Once both images have been verified and
+// staged their compatibility, as declared in their manifests, is verified and
+// both are activated together. If successful the applet will be stopped and
+// the device will reboot.
+//
+// An interrupted activation is completed on the next boot, so that the OS and
+// applet are never left mismatched.
+func (r *RPC) InstallCombined(u *rpc.CombinedUpdate, status *rpc.CombinedUpdateStatus) error {
+	for _, c := range []struct {
+		t      FirmwareType
+		update *rpc.FirmwareUpdate
+		status *rpc.FirmwareUpdateStatus
+		staged *bool
+	}{
+		{Firmware_OS, u.OS, &status.OS, &combinedStaged.os},
+		{Firmware_Applet, u.Applet, &status.Applet, &combinedStaged.applet},
+	} {
+		if c.update == nil {
+			continue
+		}
+
+		if c.update.Sequence == 0 {
+			*c.staged = false
+		}
+
+		staged, err := r.stage(otaApplet, c.t, c.update, c.status, true)
+		if err != nil {
+			return err
+		}
+
+		if staged {
+			*c.staged = true
+		}
+	}
+
+	status.OSStaged = combinedStaged.os
+	status.AppletStaged = combinedStaged.applet
+
+	if !combinedStaged.os || !combinedStaged.applet {
 		return nil
 	}
 
-	if err := updateOS(r.Storage, osFirmwareBuffer, b.Proof); err != nil {
+	combinedStaged.open = false
+	combinedStaged.os = false
+	combinedStaged.applet = false
+
+	if err := activateCombined(r.Storage, r.RPMB); err != nil {
 		return err
 	}
-	r.Ctx.Stop()
-	<-r.Ctx.Done()
 
-	return r.Reboot(nil, nil)
-}
+	r.rebootAfterExit()
 
-var osFirmwareBuffer []byte
+	return nil
+}
 
-// InstallApplet updates the Applet to the version contained in the firmware bundle.
-// This RPC supports sending the (potentially large) firmware image either:
-// - In one RPC call, or
-// - Spread over multiple RPC calls, breaking the firmware image it into multiple "chunks" of arbitrary size
+// stage receives a firmware update chunk from the given client, once the
+// firmware image is complete the update is verified and staged to internal
+// storage. Chunks are rejected while an upload of the same firmware type is
+// in progress from the other client.
 //
-// For a given install attempt:
-//   - An RPC call with the Sequence field set to zero indicates a fresh attempt to install firmware.
-//   - If firmware is being sent in chunks via multiple RPC calls, each subsequent RPC call should:
-//     1. increment the Sequence field by 1 each time.
-//     2. Pass a chunk of firmware image which is contiguous with the previous chunk.
-//   - An RPC call with the Proof set to a non-zero value indicates that all firmware chunks have been sent.
-//     This will cause the firmware update to be finalised, and if successful, this RPC will not
-//     return and the device will reboot.
-func (r *RPC) InstallApplet(b *rpc.FirmwareUpdate, _ *bool) error {
-	if b.Sequence == 0 {
-		// Dump previous partial attempts
-		appletFirmwareBuffer = make([]byte, 0, len(b.Image))
+// Unless the update is part of a combined update, its compatibility with the
+// running firmware of the other type is also verified, and it is rejected
+// while a combined update is in progress as both share the upload buffers and
+// staging slots.
+func (r *RPC) stage(c otaClient, t FirmwareType, b *rpc.FirmwareUpdate, status *rpc.FirmwareUpdateStatus, combined bool) (bool, error) {
+	otaLock.Lock()
+	defer otaLock.Unlock()
+
+	o, err := uploadFor(t)
+	if err != nil {
+		return false, err
+	}
+
+	if err = o.claim(c, true); err != nil {
+		return false, err
+	}
+
+	if combined {
+		combinedStaged.open = true
+	} else if err = checkCombined(r.Storage, b); err != nil {
+		return false, err
 	}
-	// Extend our firmware buffer
-	appletFirmwareBuffer = append(appletFirmwareBuffer, b.Image...)
+
+	err = o.append(b)
 	b.Image = nil
+	*status = o.status()
+
+	if err != nil {
+		return false, err
+	}
+
+	if len(b.ProofBundle) > 0 {
+		pb, err := api.ParseProofBundle(b.ProofBundle)
+		if err != nil {
+			return false, err
+		}
+		b.Proof = pb.Config()
+	}
 
 	// Return early if we're don't yet have the full image.
 	if len(b.Proof.Checkpoint) == 0 {
+		return false, nil
+	}
+
+	// The image is discarded regardless of the outcome, as a failed
+	// update cannot be completed by further chunks.
+	defer o.reset()
+
+	elf := o.buf
+
+	if len(b.DeltaBase) > 0 {
+		if elf, err = r.patch(t, b.DeltaBase, o.buf); err != nil {
+			return false, err
+		}
+	}
+
+	if err = update(r.Storage, r.RPMB, t, elf, b.Proof, b.ConsistencyProof, !combined); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// patch reconstructs a firmware image from a binary delta against the active
+// firmware image, the result is subject to the same verification as any
+// complete firmware image.
+func (r *RPC) patch(t FirmwareType, base []byte, d []byte) ([]byte, error) {
+	digest, err := delta.Base(d)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(digest, base) {
+		return nil, fmt.Errorf("delta base mismatch (%x != %x)", digest, base)
+	}
+
+	active, err := readActive(r.Storage, t)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read active %s: %v", t, err)
+	}
+
+	log.Printf("SM applying %s delta (%d bytes) to active image (%d bytes)", t, len(d), len(active))
+
+	return delta.Patch(active, d, otaLimit)
+}
+
+// VerifyBundle verifies a firmware bundle, without installing it, performing
+// the same checks as an install would.
+//
+// Bundles failing verification are not reported as an RPC error, the reason
+// for rejection is instead returned in the result Rejection field.
+func (r *RPC) VerifyBundle(req rpc.VerifyBundle, res *rpc.BundleVerification) error {
+	*res = rpc.BundleVerification{}
+
+	manifest, err := verifyFirmware(r.RPMB, req.Type, req.Bundle)
+	if err == nil {
+		err = checkConsistency(r.RPMB, req.Bundle.Checkpoint, req.ConsistencyProof)
+	}
+
+	var rejection *rpc.BundleRejection
+	switch {
+	case errors.As(err, &rejection):
+		res.Rejection = rejection
+	case err != nil:
+		return err
+	default:
+		res.Manifest = manifest
+	}
+
+	return nil
+}
+
+// LatestCheckpoints returns the latest checkpoint verified by the OS for each
+// accepted firmware log.
+func (r *RPC) LatestCheckpoints(_ *any, states *[]rpc.LogState) error {
+	if !rollbackProtection() {
+		return errors.New("checkpoint tracking is not available")
+	}
+
+	*states = nil
+
+	for _, l := range acceptedLogs {
+		s := rpc.LogState{
+			Origin: l.Origin,
+		}
+
+		latest, err := latestLogState(r.RPMB, l.Origin)
+		if err != nil {
+			return err
+		}
+
+		if latest != nil {
+			s.Size = latest.Size
+			s.Hash = latest.Hash[:]
+		}
+
+		*states = append(*states, s)
+	}
+
+	return nil
+}
+
+// UpdateRevocations installs a new manifest signer revocation list, which
+// must be signed by the revocation key and have a version greater than the
+// list in effect.
+func (r *RPC) UpdateRevocations(buf []byte, _ *bool) error {
+	l, err := updateRevocations(r.Storage, r.RPMB, buf)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("SM revocation list version %d (%d revoked keys) in effect", l.Version, len(l.Revoked))
+	revocations = l
+	revocationsErr = nil
+
+	return nil
+}
+
+// Revocations returns the manifest signer revocation list in effect.
+func (r *RPC) Revocations(_ *any, res *rpc.Revocations) error {
+	if revocationsErr != nil {
+		return revocationsErr
+	}
+
+	*res = rpc.Revocations{
+		Version: revocations.Version,
+		Revoked: revocations.Revoked,
+	}
+
+	return nil
+}
+
+// InstallStatus returns the progress of the chunked firmware update, for the
+// given firmware type, which is currently in progress.
+func (r *RPC) InstallStatus(t rpc.FirmwareType, status *rpc.FirmwareUpdateStatus) error {
+	return installStatus(otaApplet, t, status)
+}
+
+// ActivateStaged activates the staged update for the given firmware type, if
+// successful the applet will be stopped and the device will reboot into the
+// updated firmware.
+func (r *RPC) ActivateStaged(t rpc.FirmwareType, _ *bool) error {
+	if err := activateFirmware(r.Storage, r.RPMB, t); err != nil {
+		return err
+	}
+
+	r.rebootAfterExit()
+
+	return nil
+}
+
+// StagedFirmware returns information on the staged update, if any, for the
+// given firmware type.
+func (r *RPC) StagedFirmware(t rpc.FirmwareType, staged *rpc.StagedFirmware) error {
+	conf, err := readStaged(r.Storage, t)
+	if err != nil {
+		return err
+	}
+
+	*staged = rpc.StagedFirmware{}
+
+	if conf == nil {
 		return nil
 	}
-	if err := updateApplet(r.Storage, appletFirmwareBuffer, b.Proof); err != nil {
+
+	// The manifest has been verified when staging, it is only parsed here.
+	manifest, err := api.ParseManifest(conf.Bundle.Manifest)
+	if err != nil {
 		return err
 	}
-	r.Ctx.Stop()
-	// This must be done in a go-routine because the ExecCtx.Stop() above can only be
-	// actioned once the RPC has returned.
+
+	staged.Staged = true
+	staged.Version = manifest.Git.TagName
+	staged.Size = conf.Size
+	staged.Proof = conf.Bundle
+	staged.Signers = manifestSigners(t, conf.Bundle.Manifest)
+
+	return nil
+}
+
+// DiscardStaged discards the staged update, if any, for the given firmware
+// type.
+//
+// Discarding either firmware type also abandons any combined update in
+// progress.
+func (r *RPC) DiscardStaged(t rpc.FirmwareType, _ *bool) error {
+	otaLock.Lock()
+	combinedStaged.open = false
+	combinedStaged.os = false
+	combinedStaged.applet = false
+	otaLock.Unlock()
+
+	return discardStaged(r.Storage, t)
+}
+
+// rebootAfterExit stops the Trusted Applet and resets the system once it has
+// exited, it allows RPCs to return before the reset.
+func (r *RPC) rebootAfterExit() {
+	// This must be done in a go-routine because stopping the applet can
+	// only be actioned once the RPC has returned.
 	go func() {
-		<-r.Ctx.Done()
+		haltApplet(r)
 		r.Reboot(nil, nil)
 	}()
-
-	return r.Reboot(nil, nil)
 }
 
-var appletFirmwareBuffer []byte
-
 // Reboot resets the system.
 func (r *RPC) Reboot(_ *any, _ *bool) error {
 	log.Printf("SM rebooting")
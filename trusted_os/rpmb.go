@@ -49,6 +49,10 @@ const (
 	taVersionSector = 2
 	// RPMB sector for TA use
 	taUserSector = 3
+	// RPMB sector for firmware log checkpoint tracking
+	checkpointSector = 4
+	// RPMB sector for revocation list anchoring
+	revocationSector = 5
 	// RPMB OTP flag bank
 	rpmbFuseBank = 4
 	// RPMB OTP flag word
@@ -223,6 +227,10 @@ func (r *RPMB) checkVersion(offset uint16, s string) error {
 // the input buffer can contain up to 256 bytes of data, n can be passed to
 // retrieve the partition write counter.
 func (r *RPMB) transfer(offset uint16, buf []byte, n *uint32, write bool) (err error) {
+	if r.partition == nil {
+		return errors.New("RPMB has not been initialized")
+	}
+
 	if write {
 		err = r.partition.Write(offset, buf)
 	} else {
@@ -34,6 +34,10 @@ const (
 
 	// RPMB sector for TA use
 	taUserSector = 3
+	// RPMB sector for firmware log checkpoint tracking
+	checkpointSector = 4
+	// RPMB sector for revocation list anchoring
+	revocationSector = 5
 
 	sectorLength = 256
 	numSectors   = 16
@@ -113,6 +113,18 @@ func configureHID(device *usb.Device, ctl *controlInterface) (err error) {
 		return
 	}
 
+	if err = hid.AddMapping(api.U2FHID_ARMORY_GET_CFG, ctl.GetConfig); err != nil {
+		return
+	}
+
+	if err = hid.AddMapping(api.U2FHID_ARMORY_OTA, ctl.OTA); err != nil {
+		return
+	}
+
+	if err = hid.AddMapping(api.U2FHID_ARMORY_OTA_STATUS, ctl.OTAStatus); err != nil {
+		return
+	}
+
 	if err = hid.AddMapping(api.U2FHID_ARMORY_HAB, ctl.HAB); err != nil {
 		return
 	}
@@ -124,5 +136,17 @@ func configureHID(device *usb.Device, ctl *controlInterface) (err error) {
 		return
 	}
 
+	if err = hid.AddMapping(api.U2FHID_ARMORY_FACTORY_RESET, ctl.FactoryReset); err != nil {
+		return
+	}
+
+	if err = hid.AddMapping(api.U2FHID_ARMORY_RECOVERY_SWITCH_SLOT, ctl.SwitchAppletSlot); err != nil {
+		return
+	}
+
+	if err = hid.AddMapping(api.U2FHID_ARMORY_RECOVERY_INSTALL, ctl.RecoveryInstall); err != nil {
+		return
+	}
+
 	return
 }
@@ -0,0 +1,216 @@
+// Copyright 2026 The Armored Witness OS authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/usbarmory/tamago/soc/nxp/imx6ul"
+	"golang.org/x/mod/sumdb/note"
+
+	"github.com/transparency-dev/armored-witness-boot/config"
+	"github.com/transparency-dev/armored-witness-common/release/firmware"
+	"github.com/transparency-dev/armored-witness-common/release/firmware/ftlog"
+	"github.com/transparency-dev/armored-witness-os/api/rpc"
+	"github.com/transparency-dev/armored-witness-os/internal/compress"
+	"github.com/transparency-dev/armored-witness-os/internal/quorum"
+	fmtlog "github.com/transparency-dev/formats/log"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+// appletManifestThreshold is the number of applet manifest signatures
+// required.
+var appletManifestThreshold int
+
+// rollbackProtection returns whether rollback protection, which relies on
+// RPMB, is available (see main()).
+func rollbackProtection() bool {
+	return imx6ul.Native && imx6ul.SNVS.Available()
+}
+
+// newBundle returns the firmware bundle for an image and its proof bundle.
+func newBundle(elf []byte, pb config.ProofBundle) firmware.Bundle {
+	return firmware.Bundle{
+		Checkpoint:     pb.Checkpoint,
+		Index:          pb.LogIndex,
+		InclusionProof: pb.InclusionProof,
+		Manifest:       pb.Manifest,
+		Firmware:       elf,
+	}
+}
+
+// manifestThreshold returns the number of manifest signatures required for
+// the given type of firmware.
+func manifestThreshold(t FirmwareType) int {
+	if t == Firmware_Applet {
+		return appletManifestThreshold
+	}
+
+	return len(OSBundleVerifier.ManifestVerifiers)
+}
+
+// manifestSigners returns the keys, in <name>+<hash> format, which signed a
+// manifest of the given type of firmware, signatures from unknown keys are
+// ignored.
+func manifestSigners(t FirmwareType, m []byte) (signers []string) {
+	bv := AppletBundleVerifier
+	if t == Firmware_OS {
+		bv = OSBundleVerifier
+	}
+
+	n, err := note.Open(m, note.VerifierList(bv.ManifestVerifiers...))
+	if err != nil {
+		return
+	}
+
+	for _, sig := range n.Sigs {
+		signers = append(signers, fmt.Sprintf("%s+%08x", sig.Name, sig.Hash))
+	}
+
+	return
+}
+
+func reject(reason rpc.BundleRejectionReason, format string, a ...interface{}) error {
+	return &rpc.BundleRejection{
+		Reason: reason,
+		Detail: fmt.Sprintf(format, a...),
+	}
+}
+
+// verifyFirmware verifies a firmware bundle, of the given type, returning its
+// parsed manifest.
+//
+// The bundle verifier for the firmware type is configured with the accepted
+// log matching the checkpoint origin. Besides its checks the checkpoint
+// witness cosignatures, the manifest signers against the revocation list, the
+// manifest component, the applet TamaGo runtime, the log validity for OS
+// releases and the firmware version against rollback protection, are
+// verified.
+//
+// Compressed applet images are verified after decompression.
+//
+// Verification failures are returned as *rpc.BundleRejection errors.
+func verifyFirmware(r *RPMB, t FirmwareType, b firmware.Bundle) (*ftlog.FirmwareRelease, error) {
+	var component string
+	var versionSector uint16
+
+	switch t {
+	case Firmware_Applet:
+		component = ftlog.ComponentApplet
+		versionSector = taVersionSector
+	case Firmware_OS:
+		component = ftlog.ComponentOS
+		versionSector = osVersionSector
+	default:
+		return nil, reject(rpc.RejectedFirmwareType, "unknown firmware type %v", t)
+	}
+
+	bv, l, err := bundleVerifier(t, b.Checkpoint)
+	if err != nil {
+		return nil, reject(rpc.RejectedCheckpoint, "%v", err)
+	}
+
+	if compress.IsCompressed(b.Firmware) {
+		// The bootloader is unable to decompress the OS.
+		if t == Firmware_OS {
+			return nil, reject(rpc.RejectedCompression, "compressed OS images are not supported")
+		}
+
+		elf, err := compress.Decompress(b.Firmware)
+		if err != nil {
+			return nil, reject(rpc.RejectedCompression, "%v", err)
+		}
+		b.Firmware = elf
+	}
+
+	// The witness cosignatures, manifest signature threshold and signer
+	// revocation are outside the scope of the bundle verifier.
+
+	if err := WitnessPolicy.Verify(b.Checkpoint); err != nil {
+		return nil, reject(rpc.RejectedCosignatures, "%v", err)
+	}
+
+	// The checks performed by the bundle verifier are first performed
+	// individually, so that each failure is rejected with its own reason.
+
+	cp, _, _, err := fmtlog.ParseCheckpoint(b.Checkpoint, bv.LogOrigin, bv.LogVerifer)
+	if err != nil {
+		return nil, reject(rpc.RejectedCheckpoint, "%v", err)
+	}
+
+	// The bundle verifier requires all of its manifest verifiers to sign,
+	// restrict them to the ones which have.
+	n, signers, err := quorum.Open(b.Manifest, bv.ManifestVerifiers, manifestThreshold(t))
+	if err != nil {
+		return nil, reject(rpc.RejectedManifest, "%v", err)
+	}
+	bv.ManifestVerifiers = signers
+
+	if err := checkRevocations(n); err != nil {
+		return nil, reject(rpc.RejectedRevoked, "%v", err)
+	}
+
+	release := &ftlog.FirmwareRelease{}
+	if err := json.Unmarshal([]byte(n.Text), release); err != nil {
+		return nil, reject(rpc.RejectedManifest, "invalid manifest contents: %v", err)
+	}
+
+	leafHash := rfc6962.DefaultHasher.HashLeaf(b.Manifest)
+	if err := proof.VerifyInclusion(rfc6962.DefaultHasher, b.Index, cp.Size, leafHash, b.InclusionProof, cp.Hash); err != nil {
+		return nil, reject(rpc.RejectedInclusionProof, "%v", err)
+	}
+
+	if h := sha256.Sum256(b.Firmware); !bytes.Equal(h[:], release.Output.FirmwareDigestSha256) {
+		return nil, reject(rpc.RejectedFirmwareDigest, "manifest says %x but firmware bytes hash to %x", release.Output.FirmwareDigestSha256, h)
+	}
+
+	manifest, err := bv.Verify(b)
+	if err != nil {
+		return nil, reject(rpc.RejectedManifest, "%v", err)
+	}
+
+	if manifest.Component != component {
+		return nil, reject(rpc.RejectedComponent, "got %q, want %q", manifest.Component, component)
+	}
+
+	if t == Firmware_Applet {
+		if err := checkAppletRuntime(manifest.Build.TamagoVersion); err != nil {
+			return nil, reject(rpc.RejectedRuntime, "%v", err)
+		}
+	}
+
+	// Applet and OS releases are versioned independently, the log
+	// validity refers to OS versions.
+	if t == Firmware_OS && l.Until != nil && !manifest.Git.TagName.LessThan(*l.Until) {
+		return nil, reject(rpc.RejectedCheckpoint, "log %q is only accepted for releases older than %v", l.Origin, l.Until)
+	}
+
+	if rollbackProtection() {
+		expected, err := r.expectedVersion(versionSector)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s version: %v", t, err)
+		}
+
+		if manifest.Git.TagName.LessThan(*expected) {
+			return nil, reject(rpc.RejectedRollback, "version %v is older than %v", manifest.Git.TagName, expected)
+		}
+	}
+
+	return manifest, nil
+}